@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"proxy-node/internal/core"
+	"proxy-node/internal/provider"
+)
+
+func runExportConfig(args []string) error {
+	fs := flag.NewFlagSet("export-config", flag.ContinueOnError)
+	uri := fs.String("uri", "", "share link URI")
+	inbound := fs.String("inbound", "socks", "inbound protocol: socks|http")
+	localPort := fs.Int("local-port", 0, "local proxy listen port")
+	logLevel := fs.String("log-level", "warning", "core log level")
+	out := fs.String("out", "", "write config to this file instead of stdout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *uri == "" {
+		return errors.New("--uri is required")
+	}
+	*inbound = strings.ToLower(strings.TrimSpace(*inbound))
+	if *inbound != "socks" && *inbound != "http" {
+		return errors.New("--inbound must be socks or http")
+	}
+	if *localPort == 0 {
+		*localPort = defaultLocalPort(*inbound)
+	}
+	if *localPort <= 0 || *localPort > 65535 {
+		return errors.New("--local-port must be in range 1..65535")
+	}
+
+	prov, err := provider.FromURI(*uri)
+	if err != nil {
+		return err
+	}
+	outbound, err := prov.Outbound()
+	if err != nil {
+		return err
+	}
+
+	r := core.Runner{Port: *localPort, InboundProtocol: *inbound, LogLevel: *logLevel}
+	cfg, err := r.BuildConfig(outbound)
+	if err != nil {
+		return err
+	}
+	body, err := cfg.JSON()
+	if err != nil {
+		return err
+	}
+	body = append(body, '\n')
+
+	if *out == "" {
+		_, err := os.Stdout.Write(body)
+		return err
+	}
+	if err := os.WriteFile(*out, body, 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	fmt.Printf("status=ok protocol=%s config=%s\n", prov.Name(), *out)
+	return nil
+}
+
+func runValidate(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	uri := fs.String("uri", "", "share link URI")
+	corePath := fs.String("core", "", "core binary path")
+	inbound := fs.String("inbound", "socks", "inbound protocol: socks|http")
+	localPort := fs.Int("local-port", 0, "local proxy listen port")
+	timeout := fs.Duration("timeout", 20*time.Second, "config test timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *uri == "" {
+		return errors.New("--uri is required")
+	}
+	*inbound = strings.ToLower(strings.TrimSpace(*inbound))
+	if *localPort == 0 {
+		*localPort = defaultLocalPort(*inbound)
+	}
+	resolvedCore, err := resolveCorePath(*corePath)
+	if err != nil {
+		return err
+	}
+
+	prov, err := provider.FromURI(*uri)
+	if err != nil {
+		return err
+	}
+	if err := checkCoreProtocol(resolvedCore, prov); err != nil {
+		return err
+	}
+	outbound, err := prov.Outbound()
+	if err != nil {
+		return err
+	}
+
+	r := core.Runner{CorePath: resolvedCore, Port: *localPort, InboundProtocol: *inbound}
+	cfg, err := r.BuildConfig(outbound)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := r.Validate(ctx, cfg); err != nil {
+		return err
+	}
+	fmt.Printf("status=ok protocol=%s core=%s\n", prov.Name(), resolvedCore)
+	return nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"proxy-node/internal/core"
+)
+
+func TestRunExportConfig_WritesFile(t *testing.T) {
+	t.Parallel()
+
+	out := filepath.Join(t.TempDir(), "config.json")
+	err := runExportConfig([]string{
+		"--uri", "trojan://secret@example.com:443?type=ws&path=%2Fws",
+		"--inbound", "http",
+		"--out", out,
+	})
+	if err != nil {
+		t.Fatalf("runExportConfig() error = %v", err)
+	}
+
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) error = %v", out, err)
+	}
+	var cfg map[string]any
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		t.Fatalf("Unmarshal(config) error = %v", err)
+	}
+	inbounds, _ := cfg["inbounds"].([]any)
+	if len(inbounds) != 1 {
+		t.Fatalf("inbounds = %#v, want one inbound", cfg["inbounds"])
+	}
+	in, _ := inbounds[0].(map[string]any)
+	if in["protocol"] != "http" || in["port"] != float64(8080) {
+		t.Fatalf("inbound = %#v, want http on 8080", in)
+	}
+	outbounds, _ := cfg["outbounds"].([]any)
+	first, _ := outbounds[0].(map[string]any)
+	if first["protocol"] != "trojan" {
+		t.Fatalf("outbounds[0] = %#v, want trojan", first)
+	}
+	if _, ok := cfg["log"].(map[string]any); !ok {
+		t.Fatalf("log = %#v, want log section", cfg["log"])
+	}
+}
+
+func TestRunValidate_ReportsConfigError(t *testing.T) {
+	t.Parallel()
+
+	corePath := filepath.Join(t.TempDir(), "xray")
+	script := "#!/bin/sh\necho 'infra/conf: unknown field' >&2\nexit 1\n"
+	if err := os.WriteFile(corePath, []byte(script), 0o755); err != nil {
+		t.Fatalf("WriteFile(stub core) error = %v", err)
+	}
+
+	err := runValidate([]string{"--uri", "trojan://secret@example.com:443", "--core", corePath})
+	var cfgErr *core.ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("runValidate() error = %v, want *core.ConfigError", err)
+	}
+	if !strings.Contains(err.Error(), "unknown field") {
+		t.Fatalf("error = %q, want core diagnostic", err)
+	}
+	if info := errorInfoOf(withStage(stageCore, err)); info.Stage != stageConfig {
+		t.Fatalf("errorInfoOf().Stage = %q, want %q", info.Stage, stageConfig)
+	}
+}
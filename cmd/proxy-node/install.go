@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"proxy-node/internal/core"
+	"proxy-node/internal/installer"
+	"proxy-node/internal/provider"
+)
+
+// sourceFlags say where release metadata and assets come from and how
+// they are reached.
+type sourceFlags struct {
+	apiBase *string
+	mirror  *string
+	token   *string
+	viaURI  *string
+	viaCore *string
+}
+
+func addSourceFlags(fs *flag.FlagSet) sourceFlags {
+	return sourceFlags{
+		apiBase: fs.String("api-base", envOr("PROXY_NODE_GITHUB_API", installer.DefaultAPIBase), "GitHub API base URL"),
+		mirror:  fs.String("mirror", os.Getenv("PROXY_NODE_DOWNLOAD_MIRROR"), "prefix prepended to asset download URLs"),
+		token:   fs.String("github-token", "", "GitHub token sent to the API (default $GITHUB_TOKEN)"),
+		viaURI:  fs.String("via-uri", "", "reach GitHub through this share link (starts a temporary core)"),
+		viaCore: fs.String("core", "", "core binary used for --via-uri (auto-detected if empty)"),
+	}
+}
+
+// viaStartTimeout bounds how long the --via-uri core may take to come up.
+const viaStartTimeout = 20 * time.Second
+
+// dial starts a temporary core for --via-uri and returns an HTTP client that
+// goes through its SOCKS inbound, plus the function tearing it down. Without
+// --via-uri the client is nil, meaning direct connections.
+func (f sourceFlags) dial(ctx context.Context, timeout time.Duration) (*http.Client, func(), error) {
+	if strings.TrimSpace(*f.viaURI) == "" {
+		return nil, func() {}, nil
+	}
+	prov, err := provider.FromURI(*f.viaURI)
+	if err != nil {
+		return nil, nil, withStage(stageInput, fmt.Errorf("--via-uri: %w", err))
+	}
+	outbound, err := prov.Outbound()
+	if err != nil {
+		return nil, nil, withStage(stageInput, fmt.Errorf("--via-uri: %w", err))
+	}
+	corePath, err := resolveCorePath(*f.viaCore)
+	if err != nil {
+		return nil, nil, withStage(stageCore, fmt.Errorf("--via-uri needs an installed core: %w", err))
+	}
+	if err := checkCoreProtocol(corePath, prov); err != nil {
+		return nil, nil, withStage(stageCore, err)
+	}
+
+	r := core.Runner{CorePath: corePath, Timeout: viaStartTimeout}
+	started, err := r.StartReady(ctx, outbound, viaStartTimeout)
+	if started == nil {
+		return nil, nil, withStage(stageCore, err)
+	}
+	warnDowngraded(os.Stderr, started)
+	if err != nil {
+		return nil, nil, coreNotReadyError(err, started, outbound)
+	}
+	socksAddr := fmt.Sprintf("127.0.0.1:%d", started.Routes[0].Port)
+	return httpClientThroughSocks(socksAddr, timeout), started.Stop, nil
+}
+
+// githubToken falls back to $GITHUB_TOKEN after parsing, so the token never
+// shows up as a flag default in help output.
+func (f sourceFlags) githubToken() string {
+	if *f.token != "" {
+		return *f.token
+	}
+	return os.Getenv("GITHUB_TOKEN")
+}
+
+func (f sourceFlags) options(repo string) installer.Options {
+	return installer.Options{
+		Repo:    repo,
+		APIBase: *f.apiBase,
+		Mirror:  *f.mirror,
+		Token:   f.githubToken(),
+	}
+}
+
+// remoteFlags are the release flags shared by install-core and
+// install-geodata.
+type remoteFlags struct {
+	sourceFlags
+	version    *string
+	dest       *string
+	force      *bool
+	skipVerify *bool
+}
+
+func addRemoteFlags(fs *flag.FlagSet) remoteFlags {
+	return remoteFlags{
+		version:     fs.String("version", "latest", "release tag or latest"),
+		dest:        fs.String("dest", ".", "install directory"),
+		force:       fs.Bool("force", false, "overwrite existing files"),
+		skipVerify:  fs.Bool("skip-verify", false, "install without checking the release checksum files"),
+		sourceFlags: addSourceFlags(fs),
+	}
+}
+
+func (f remoteFlags) options(repo string) installer.Options {
+	opts := f.sourceFlags.options(repo)
+	opts.Version = *f.version
+	opts.DestDir = *f.dest
+	opts.Force = *f.force
+	opts.SkipVerify = *f.skipVerify
+	return opts
+}
+
+func runInstallCore(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runInstallCoreList(args[1:])
+		case "update":
+			return runInstallCoreUpdate(args[1:])
+		case "rollback":
+			return runInstallCoreRollback(args[1:])
+		}
+	}
+
+	fs := flag.NewFlagSet("install-core", flag.ContinueOnError)
+	repo := fs.String("repo", "XTLS/Xray-core", "GitHub repo owner/name")
+	remote := addRemoteFlags(fs)
+	geodata := fs.Bool("geodata", false, "also install the geoip.dat/geosite.dat bundled in the archive")
+	assetPattern := fs.String("asset", "", "glob selecting the release asset instead of matching this platform")
+	cacheDir := fs.String("cache-dir", envOr("PROXY_NODE_CACHE_DIR", installer.DefaultCacheDir()), "keep downloaded archives here and resume interrupted downloads (empty disables)")
+	fromFile := fs.String("from-file", "", "install from a local .zip/.tar.gz release archive instead of GitHub")
+	timeout := fs.Duration("timeout", 2*time.Minute, "download/install timeout")
+	output := fs.String("output", "text", "output format: text|json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	opts := remote.options(*repo)
+	opts.Geodata = *geodata
+	opts.Asset = *assetPattern
+	opts.CacheDir = *cacheDir
+	opts.Progress = downloadProgress(format)
+	if *fromFile != "" {
+		return installCoreFromFile(ctx, format, *fromFile, opts)
+	}
+	rep := installReport{Status: "ok", Command: "install-core", Repo: *repo}
+	fail := func(err error) error {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(withStage(stageInstall, err))
+		return reportFailure(format, rep, err)
+	}
+	client, stop, err := remote.dial(ctx, *timeout)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+	opts.HTTPClient = client
+
+	path, tag, err := installer.Install(ctx, opts)
+	rep.Version, rep.Installed = tag, path
+	if err != nil {
+		return fail(err)
+	}
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("status=ok repo=%s version=%s installed=%s\n", *repo, tag, path)
+	return nil
+}
+
+func runInstallCoreList(args []string) error {
+	fs := flag.NewFlagSet("install-core list", flag.ContinueOnError)
+	repo := fs.String("repo", "XTLS/Xray-core", "GitHub repo owner/name")
+	source := addSourceFlags(fs)
+	limit := fs.Int("limit", 20, "number of releases to list (max 100)")
+	timeout := fs.Duration("timeout", 30*time.Second, "request timeout")
+	output := fs.String("output", "text", "output format: text|json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	rep := releaseListReport{Status: "ok", Command: "install-core list", Repo: *repo}
+	fail := func(err error) error {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(withStage(stageInstall, err))
+		return reportFailure(format, rep, err)
+	}
+	client, stop, err := source.dial(ctx, *timeout)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+	opts := source.options(*repo)
+	opts.HTTPClient = client
+
+	releases, err := installer.ListReleases(ctx, opts, *limit)
+	rep.Releases = releases
+	if err != nil {
+		return fail(err)
+	}
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	for _, r := range releases {
+		line := r.Tag
+		if !r.PublishedAt.IsZero() {
+			line += "\t" + r.PublishedAt.Format("2006-01-02")
+		}
+		if r.Prerelease {
+			line += "\tprerelease"
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// runInstallCoreUpdate installs the requested release only when it is newer
+// than what the installed binary reports. A binary that cannot be detected
+// is replaced.
+func runInstallCoreUpdate(args []string) error {
+	fs := flag.NewFlagSet("install-core update", flag.ContinueOnError)
+	repo := fs.String("repo", "XTLS/Xray-core", "GitHub repo owner/name")
+	remote := addRemoteFlags(fs)
+	geodata := fs.Bool("geodata", false, "also install the geoip.dat/geosite.dat bundled in the archive")
+	assetPattern := fs.String("asset", "", "glob selecting the release asset instead of matching this platform")
+	cacheDir := fs.String("cache-dir", envOr("PROXY_NODE_CACHE_DIR", installer.DefaultCacheDir()), "keep downloaded archives here and resume interrupted downloads (empty disables)")
+	timeout := fs.Duration("timeout", 2*time.Minute, "download/install timeout")
+	output := fs.String("output", "text", "output format: text|json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	opts := remote.options(*repo)
+	opts.Geodata = *geodata
+	opts.Asset = *assetPattern
+	opts.CacheDir = *cacheDir
+	opts.Progress = downloadProgress(format)
+	opts.Force = true
+	path := installer.BinaryPath(opts)
+	rep := installReport{Status: "ok", Command: "install-core update", Repo: *repo, Installed: path}
+	fail := func(err error) error {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(withStage(stageInstall, err))
+		return reportFailure(format, rep, err)
+	}
+
+	client, stop, err := remote.dial(ctx, *timeout)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+	opts.HTTPClient = client
+
+	tag, err := installer.ResolveTag(ctx, opts)
+	if err != nil {
+		return fail(err)
+	}
+	rep.Version = tag
+	if info, detectErr := core.Detect(ctx, path); detectErr == nil {
+		rep.Previous = info.Banner
+		if latest, ok := core.ParseVersion(tag); ok && info.Version.AtLeast(latest) {
+			rep.UpToDate = true
+			rep.CoreVersion = info.Banner
+			if format != formatText {
+				return writeJSON(os.Stdout, rep)
+			}
+			fmt.Printf("status=ok repo=%s version=%s installed=%s up_to_date=true\n", *repo, tag, path)
+			return nil
+		}
+	}
+
+	opts.Version = tag
+	if _, _, err := installer.Install(ctx, opts); err != nil {
+		return fail(err)
+	}
+	if info, err := core.Detect(ctx, path); err == nil {
+		rep.CoreVersion = info.Banner
+	}
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("status=ok repo=%s version=%s installed=%s previous=%q\n", *repo, tag, path, rep.Previous)
+	return nil
+}
+
+func runInstallCoreRollback(args []string) error {
+	fs := flag.NewFlagSet("install-core rollback", flag.ContinueOnError)
+	repo := fs.String("repo", "XTLS/Xray-core", "GitHub repo owner/name (selects the binary name)")
+	dest := fs.String("dest", ".", "install directory")
+	output := fs.String("output", "text", "output format: text|json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
+
+	path := installer.BinaryPath(installer.Options{Repo: *repo, DestDir: *dest})
+	rep := installReport{Status: "ok", Command: "install-core rollback", Repo: *repo, Installed: path}
+	if err := installer.Rollback(path); err != nil {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(withStage(stageInstall, err))
+		return reportFailure(format, rep, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if info, err := core.Detect(ctx, path); err == nil {
+		rep.CoreVersion = info.Banner
+	}
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("status=ok installed=%s core_version=%q\n", path, rep.CoreVersion)
+	return nil
+}
+
+// installCoreFromFile is the offline path of install-core. Nothing identifies
+// the release, so the installed binary is asked for its version instead.
+func installCoreFromFile(ctx context.Context, format outputFormat, archive string, opts installer.Options) error {
+	path, err := installer.InstallFromFile(archive, opts)
+	rep := installReport{Status: "ok", Command: "install-core", Source: archive, Installed: path}
+	if err != nil {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(withStage(stageInstall, err))
+		return reportFailure(format, rep, err)
+	}
+	info, err := core.Detect(ctx, path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: installed %s but could not detect its version: %v\n", path, err)
+	}
+	if info != nil {
+		rep.CoreVersion = info.Banner
+	}
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("status=ok source=%s installed=%s core_version=%q\n", archive, path, rep.CoreVersion)
+	return nil
+}
+
+func runInstallGeodata(args []string) error {
+	fs := flag.NewFlagSet("install-geodata", flag.ContinueOnError)
+	repo := fs.String("repo", installer.DefaultGeodataRepo, "GitHub repo publishing the rule files")
+	remote := addRemoteFlags(fs)
+	files := fs.String("files", strings.Join(installer.GeodataFiles, ","), "comma-separated release assets to install")
+	timeout := fs.Duration("timeout", 2*time.Minute, "download/install timeout")
+	output := fs.String("output", "text", "output format: text|json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	var names []string
+	for _, name := range strings.Split(*files, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	rep := installReport{Status: "ok", Command: "install-geodata", Repo: *repo}
+	fail := func(err error) error {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(withStage(stageInstall, err))
+		return reportFailure(format, rep, err)
+	}
+	client, stop, err := remote.dial(ctx, *timeout)
+	if err != nil {
+		return fail(err)
+	}
+	defer stop()
+	opts := remote.options(*repo)
+	opts.Progress = downloadProgress(format)
+	opts.HTTPClient = client
+
+	paths, tag, err := installer.InstallGeodata(ctx, opts, names)
+	rep.Version, rep.Files = tag, paths
+	if err != nil {
+		return fail(err)
+	}
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("status=ok repo=%s version=%s installed=%s\n", *repo, tag, strings.Join(paths, ","))
+	return nil
+}
+
+// downloadProgress renders installer progress on stderr for text output:
+// a live line on a terminal, one summary line per asset otherwise.
+func downloadProgress(format outputFormat) func(installer.Progress) {
+	if format != formatText {
+		return nil
+	}
+	live := isTerminal(os.Stderr)
+	return func(p installer.Progress) {
+		if p.Cached {
+			fmt.Fprintf(os.Stderr, "using cached %s (%s)\n", p.Asset, humanBytes(uint64(p.Done)))
+			return
+		}
+		if !live && !p.Finished {
+			return
+		}
+		line := fmt.Sprintf("downloading %s %s", p.Asset, humanBytes(uint64(p.Done)))
+		if p.Total > 0 {
+			line += fmt.Sprintf("/%s (%d%%)", humanBytes(uint64(p.Total)), p.Done*100/p.Total)
+		}
+		if secs := p.Elapsed.Seconds(); secs > 0 {
+			line += fmt.Sprintf(" %s/s", humanBytes(uint64(float64(p.Done-p.Resumed)/secs)))
+		}
+		if p.Resumed > 0 {
+			line += fmt.Sprintf(" resumed at %s", humanBytes(uint64(p.Resumed)))
+		}
+		if live {
+			fmt.Fprintf(os.Stderr, "\r\033[K%s", line)
+			if p.Finished {
+				fmt.Fprintln(os.Stderr)
+			}
+			return
+		}
+		fmt.Fprintln(os.Stderr, line)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSourceFlagsDial(t *testing.T) {
+	t.Parallel()
+
+	parse := func(args ...string) sourceFlags {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		f := addSourceFlags(fs)
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	client, stop, err := parse().dial(context.Background(), time.Second)
+	if err != nil || client != nil {
+		t.Fatalf("dial() without --via-uri = %v, %v; want direct", client, err)
+	}
+	stop()
+
+	_, _, err = parse("--via-uri", "bogus://x").dial(context.Background(), time.Second)
+	if info := errorInfoOf(err); info == nil || info.Stage != stageInput {
+		t.Fatalf("dial(bogus link) error = %v, want input stage", err)
+	}
+
+	_, _, err = parse("--via-uri", "trojan://secret@example.com:443", "--core", "/nonexistent/xray").dial(context.Background(), time.Second)
+	if info := errorInfoOf(err); info == nil || info.Stage != stageCore {
+		t.Fatalf("dial(missing core) error = %v, want core stage", err)
+	}
+
+	// /bin/true exits at once without ever reporting that it started.
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, _, err = parse("--via-uri", "trojan://secret@example.com:443", "--core", "/bin/true").dial(ctx, time.Second)
+	if info := errorInfoOf(err); info == nil || info.Stage != stageReady {
+		t.Fatalf("dial(dead core) error = %v, want ready stage", err)
+	}
+}
+
+func TestSourceFlags_TokenNotInHelp(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "ghp_secret")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	f := addSourceFlags(fs)
+	var help strings.Builder
+	fs.SetOutput(&help)
+	fs.PrintDefaults()
+	if strings.Contains(help.String(), "ghp_secret") {
+		t.Fatalf("help output leaks the token:\n%s", help.String())
+	}
+	if err := fs.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.options("XTLS/Xray-core").Token; got != "ghp_secret" {
+		t.Fatalf("Token = %q, want $GITHUB_TOKEN", got)
+	}
+	if err := fs.Parse([]string{"--github-token", "ghp_flag"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := f.options("XTLS/Xray-core").Token; got != "ghp_flag" {
+		t.Fatalf("Token = %q, want the flag value", got)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"fmt"
 	"io"
 	"math"
-	"math/rand"
 	"net"
 	"net/http"
 	"net/url"
@@ -16,6 +15,7 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -25,7 +25,6 @@ import (
 	"github.com/rivo/tview"
 
 	"proxy-node/internal/core"
-	"proxy-node/internal/installer"
 	"proxy-node/internal/provider"
 	"proxy-node/internal/proxy"
 )
@@ -45,30 +44,27 @@ func main() {
 	sub := os.Args[1]
 	switch sub {
 	case "probe":
-		if err := runProbe(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "probe failed: %v\n", err)
-			os.Exit(1)
-		}
+		exitOnError("probe", runProbe(os.Args[2:]))
 	case "speed":
-		if err := runSpeed(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "speed failed: %v\n", err)
-			os.Exit(1)
-		}
+		exitOnError("speed", runSpeed(os.Args[2:]))
 	case "socks":
-		if err := runProxy(os.Args[2:], "socks"); err != nil {
-			fmt.Fprintf(os.Stderr, "socks failed: %v\n", err)
-			os.Exit(1)
-		}
+		exitOnError("socks", runProxy(os.Args[2:], "socks"))
 	case "proxy":
-		if err := runProxy(os.Args[2:], "socks"); err != nil {
-			fmt.Fprintf(os.Stderr, "proxy failed: %v\n", err)
-			os.Exit(1)
-		}
+		exitOnError("proxy", runProxy(os.Args[2:], "socks"))
+	case "probe-all":
+		exitOnError("probe-all", runProbeAll(os.Args[2:]))
+	case "subscribe":
+		exitOnError("subscribe", runSubscribe(os.Args[2:]))
+	case "normalize":
+		exitOnError("normalize", runNormalize(os.Args[2:]))
+	case "export-config":
+		exitOnError("export-config", runExportConfig(os.Args[2:]))
+	case "validate":
+		exitOnError("validate", runValidate(os.Args[2:]))
 	case "install-core":
-		if err := runInstallCore(os.Args[2:]); err != nil {
-			fmt.Fprintf(os.Stderr, "install-core failed: %v\n", err)
-			os.Exit(1)
-		}
+		exitOnError("install-core", runInstallCore(os.Args[2:]))
+	case "install-geodata":
+		exitOnError("install-geodata", runInstallGeodata(os.Args[2:]))
 	case "help", "-h", "--help":
 		usage()
 	default:
@@ -78,25 +74,52 @@ func main() {
 	}
 }
 
+func exitOnError(cmd string, err error) {
+	if err == nil {
+		return
+	}
+	var reported *reportedError
+	if !errors.As(err, &reported) {
+		fmt.Fprintf(os.Stderr, "%s failed: %v\n", cmd, err)
+	}
+	os.Exit(1)
+}
+
+// envOr returns the environment variable key, or def when it is unset or empty.
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
 func usage() {
 	fmt.Print(`proxy-node - v2ray/xray outbound health checker
 
 Usage:
-  proxy-node probe --uri <vless|vmess URI> [--core <path to xray/v2ray>]
-  proxy-node speed --uri <vless|vmess URI> [--core <path to xray/v2ray>]
+  proxy-node probe --uri <vless|vmess|ss|trojan|hysteria2 URI> [--core <path to xray/v2ray>]
+  proxy-node speed --uri <vless|vmess|ss|trojan|hysteria2 URI> [--core <path to xray/v2ray>]
 
 Commands:
   probe   Start core with generated config and run an HTTP probe through SOCKS5.
   speed   Start core and measure download speed through SOCKS5.
   socks   Alias of proxy --inbound socks.
   proxy   Start core and keep a local proxy (SOCKS5/HTTP) port open until interrupted.
+  probe-all  Probe many links concurrently and print a table ranked by latency.
+  subscribe  Fetch a subscription feed, parse every link and optionally probe each node.
+  normalize  Print canonical share links, dropping duplicate nodes.
+  export-config  Print the core config generated for a link without starting the core.
+  validate  Check the generated config with the core's own test mode.
   install-core  Download and install Xray/V2Ray core from GitHub release.
+  install-geodata  Download geoip.dat/geosite.dat from a rule-set release.
 
 Common flags:
-  --uri string          VLESS/VMess URI
+  --uri string          VLESS/VMess/Shadowsocks/Trojan URI
   --core string         core binary path (optional, auto-detected if empty)
-  --local-socks int     local SOCKS port (default: random 20000-40000)
+  --local-socks int     local SOCKS port (default: a free port)
   --timeout duration    timeout for startup and checks (default: 20s)
+  --output string       probe/speed/install-core output: text|json (default: text)
+  --keep-temp           keep the core's temp config and logs (they hold credentials; removed by default)
 
 Probe flags:
   --url string          probe URL (default: https://www.cloudflare.com/cdn-cgi/trace)
@@ -109,125 +132,241 @@ Speed flags:
 Proxy flags:
   --inbound string      inbound protocol: socks|http (default: socks)
   --local-port int      local proxy listen port (default: 1080 for socks, 8080 for http)
+  --listen string       listen address, e.g. 0.0.0.0 or [::] to share on the LAN (default: 127.0.0.1)
+  --auth user:pass      require this username and password on the proxy
   --print-requests      stream core log lines while running
   --no-traffic          disable live uplink/downlink bytes per second output
   --traffic-interval    traffic refresh interval (default: 2s)
   --timeout duration    startup timeout (default: 20s)
 
+Probe-all flags:
+  --uri string          share link URI (repeatable; positional args are also accepted)
+  --sub string          subscription URL, file path, or - for stdin (repeatable)
+  --workers int         concurrent cores, each on its own free local port (default: 8)
+  --url string          probe URL
+  --timeout duration    per-node probe timeout (default: 20s)
+  --single-core         run every node through one core process (one inbound per node)
+  --output string       text|json|ndjson (default: text)
+
+Subscribe flags:
+  --sub string          subscription URL, file path, or - for stdin
+  --probe               probe every parsed node (one core at a time)
+  --url string          probe URL used with --probe
+  --timeout duration    fetch timeout and per-node probe timeout (default: 20s)
+  --output string       text|json|ndjson (default: text)
+
+Normalize flags:
+  --uri string          share link URI (repeatable; positional args are also accepted)
+  --sub string          subscription URL, file path, or - for stdin (repeatable)
+  --keep-duplicates     print duplicate nodes too
+
+Export-config flags:
+  --uri string          share link URI
+  --inbound string      inbound protocol: socks|http (default: socks)
+  --local-port int      inbound listen port (default: 1080 for socks, 8080 for http)
+  --log-level string    core log level (default: warning)
+  --out string          write to file instead of stdout
+
+Validate flags:
+  --uri string          share link URI
+  --core string         core binary path (optional, auto-detected if empty)
+  --inbound string      inbound protocol: socks|http (default: socks)
+  --local-port int      inbound listen port (default: 1080 for socks, 8080 for http)
+
 Install-core flags:
   --repo string         GitHub repo owner/name (default: XTLS/Xray-core)
   --version string      release tag or "latest" (default: latest)
   --dest string         install directory (default: current dir)
   --force               overwrite existing binary if present
+  --skip-verify         do not check the archive against the release .dgst checksum
+  --api-base string     GitHub API base URL (env PROXY_NODE_GITHUB_API, default: https://api.github.com)
+  --mirror string       prefix prepended to asset download URLs (env PROXY_NODE_DOWNLOAD_MIRROR)
+  --github-token string bearer token for the GitHub API (env GITHUB_TOKEN)
+  --via-uri string      reach GitHub through this share link via a temporary local core
+  --core string         core used for --via-uri (optional, auto-detected if empty)
+  --geodata             also install geoip.dat/geosite.dat bundled in the archive
+  --asset string        glob picking the release asset (e.g. "*linux-arm32-v6*") instead of auto-matching
+  --cache-dir string    reuse verified archives and resume downloads here (env PROXY_NODE_CACHE_DIR,
+                        default: user cache dir; empty disables)
+  --from-file string    install offline from a local .zip/.tar.gz release archive
+
+Install-core subcommands:
+  install-core list [--repo] [--limit 20]   list available release tags
+  install-core update [install-core flags]  install only if newer than the installed binary
+  install-core rollback [--repo] [--dest]   restore the binary replaced by the last install (<name>.bak)
+
+Install-geodata flags:
+  --repo string         rule-set repo owner/name (default: Loyalsoldier/v2ray-rules-dat)
+  --files string        comma-separated assets to install (default: geoip.dat,geosite.dat)
+  --version, --dest, --force, --skip-verify, --api-base, --mirror, --github-token,
+  --via-uri, --core     same as install-core
 `)
 }
 
 func runProbe(args []string) error {
 	fs := flag.NewFlagSet("probe", flag.ContinueOnError)
-	uri := fs.String("uri", "", "VLESS/VMess URI")
+	uri := fs.String("uri", "", "share link URI")
 	corePath := fs.String("core", "", "core binary path")
 	probeURL := fs.String("url", defaultProbeURL, "probe URL")
 	timeout := fs.Duration("timeout", 20*time.Second, "timeout")
-	localPort := fs.Int("local-socks", 0, "local socks port")
+	localPort := fs.Int("local-socks", 0, "local socks port (default: a free port)")
+	output := fs.String("output", "text", "output format: text|json")
+	keepTemp := fs.Bool("keep-temp", false, "keep the core's temp config and logs after exit (for debugging)")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
 	if *uri == "" {
 		return errors.New("--uri is required")
 	}
+
+	rep := probeReport{Status: "ok", Command: "probe"}
+	fail := func(err error) error {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(err)
+		return reportFailure(format, rep, err)
+	}
+
+	prov, err := provider.FromURI(*uri)
+	if err != nil {
+		return fail(withStage(stageInput, err))
+	}
+	rep.Protocol = prov.Name()
+	rep.Remark = provider.LabelOf(prov)
+	rep.Address = provider.EndpointOf(prov)
+
 	resolvedCore, err := resolveCorePath(*corePath)
 	if err != nil {
-		return err
+		return fail(withStage(stageCore, err))
+	}
+	if err := checkCoreProtocol(resolvedCore, prov); err != nil {
+		return fail(withStage(stageCore, err))
 	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
 	defer cancel()
 
-	prov, err := provider.FromURI(*uri)
+	res, err := probeProvider(ctx, resolvedCore, prov, *localPort, *probeURL, *timeout, *keepTemp)
 	if err != nil {
-		return err
+		return fail(err)
 	}
+	rep.LatencyMS = res.Latency.Milliseconds()
+	rep.Code = res.Code
+	rep.Bytes = res.Bytes
+
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
+	}
+	fmt.Printf("status=ok protocol=%s code=%d latency_ms=%d bytes=%d%s\n", prov.Name(), res.Code, res.Latency.Milliseconds(), res.Bytes, remarkField(prov))
+	return nil
+}
+
+type probeResult struct {
+	Latency time.Duration
+	Code    int
+	Bytes   int64
+}
+
+// probeProvider starts a core for prov on the given local SOCKS port (a free
+// one when port is 0), runs one HTTP probe through it and stops the core
+// again.
+func probeProvider(ctx context.Context, corePath string, prov provider.Provider, port int, probeURL string, timeout time.Duration, keepTemp bool) (probeResult, error) {
 	outbound, err := prov.Outbound()
 	if err != nil {
-		return err
-	}
-	port := *localPort
-	if port == 0 {
-		port = randomPort()
+		return probeResult{}, withStage(stageInput, err)
 	}
 
-	r := core.Runner{CorePath: resolvedCore, Port: port, Timeout: *timeout}
-	started, err := r.Start(ctx, outbound)
-	if err != nil {
-		return err
+	r := core.Runner{CorePath: corePath, Port: port, Timeout: timeout, KeepTempDir: keepTemp}
+	started, err := r.StartReady(ctx, outbound, timeout)
+	if started == nil {
+		return probeResult{}, withStage(stageCore, err)
 	}
 	defer started.Stop()
-
-	socksAddr := fmt.Sprintf("127.0.0.1:%d", port)
-	if err := waitSocks(ctx, socksAddr, *timeout); err != nil {
-		return fmt.Errorf("core did not become ready: %w\n%s", err, coreLogTails(started))
+	warnDowngraded(os.Stderr, started)
+	if err != nil {
+		return probeResult{}, coreNotReadyError(err, started, outbound)
 	}
 
-	latency, code, n, err := probeHTTP(ctx, socksAddr, *probeURL, *timeout)
+	socksAddr := fmt.Sprintf("127.0.0.1:%d", started.Routes[0].Port)
+
+	latency, code, n, err := probeHTTP(ctx, socksAddr, probeURL, timeout)
 	if err != nil {
-		return fmt.Errorf("probe request failed: %w\n%s", err, coreLogTails(started))
+		return probeResult{}, coreFailure(stageRequest, "probe request failed", err, started)
 	}
-
-	fmt.Printf("status=ok protocol=%s code=%d latency_ms=%d bytes=%d\n", prov.Name(), code, latency.Milliseconds(), n)
-	return nil
+	return probeResult{Latency: latency, Code: code, Bytes: n}, nil
 }
 
 func runSpeed(args []string) error {
 	fs := flag.NewFlagSet("speed", flag.ContinueOnError)
-	uri := fs.String("uri", "", "VLESS/VMess URI")
+	uri := fs.String("uri", "", "share link URI")
 	corePath := fs.String("core", "", "core binary path")
 	speedURL := fs.String("url", defaultSpeedURL, "speed test URL")
 	maxBytes := fs.Int64("max-bytes", 10*1024*1024, "max bytes to download (0 for full)")
 	retries := fs.Int("retries", defaultSpeedRetries, "retry count on failure")
 	timeout := fs.Duration("timeout", 45*time.Second, "timeout")
-	localPort := fs.Int("local-socks", 0, "local socks port")
+	localPort := fs.Int("local-socks", 0, "local socks port (default: a free port)")
+	output := fs.String("output", "text", "output format: text|json")
+	keepTemp := fs.Bool("keep-temp", false, "keep the core's temp config and logs after exit (for debugging)")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	format, err := parseOutputFormat(*output, false)
+	if err != nil {
+		return err
+	}
 	if *uri == "" {
 		return errors.New("--uri is required")
 	}
 	if *retries < 1 {
 		return errors.New("--retries must be >= 1")
 	}
-	resolvedCore, err := resolveCorePath(*corePath)
-	if err != nil {
-		return err
-	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
-	defer cancel()
+	rep := speedReport{Status: "ok", Command: "speed"}
+	fail := func(err error) error {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(err)
+		return reportFailure(format, rep, err)
+	}
 
 	prov, err := provider.FromURI(*uri)
 	if err != nil {
-		return err
+		return fail(withStage(stageInput, err))
 	}
-	outbound, err := prov.Outbound()
+	rep.Protocol = prov.Name()
+	rep.Remark = provider.LabelOf(prov)
+	rep.Address = provider.EndpointOf(prov)
+
+	resolvedCore, err := resolveCorePath(*corePath)
 	if err != nil {
-		return err
+		return fail(withStage(stageCore, err))
 	}
-	port := *localPort
-	if port == 0 {
-		port = randomPort()
+	if err := checkCoreProtocol(resolvedCore, prov); err != nil {
+		return fail(withStage(stageCore, err))
 	}
-
-	r := core.Runner{CorePath: resolvedCore, Port: port, Timeout: *timeout}
-	started, err := r.Start(ctx, outbound)
+	outbound, err := prov.Outbound()
 	if err != nil {
-		return err
+		return fail(withStage(stageInput, err))
 	}
-	defer started.Stop()
 
-	socksAddr := fmt.Sprintf("127.0.0.1:%d", port)
-	if err := waitSocks(ctx, socksAddr, *timeout); err != nil {
-		return fmt.Errorf("core did not become ready: %w\n%s", err, coreLogTails(started))
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	r := core.Runner{CorePath: resolvedCore, Port: *localPort, Timeout: *timeout, KeepTempDir: *keepTemp}
+	started, err := r.StartReady(ctx, outbound, *timeout)
+	if started == nil {
+		return fail(withStage(stageCore, err))
+	}
+	defer started.Stop()
+	warnDowngraded(os.Stderr, started)
+	if err != nil {
+		return fail(coreNotReadyError(err, started, outbound))
 	}
 
+	socksAddr := fmt.Sprintf("127.0.0.1:%d", started.Routes[0].Port)
+
 	bytesRead, elapsed, attempt, partialErr, err := speedHTTPWithRetries(
 		ctx,
 		*retries,
@@ -235,57 +374,45 @@ func runSpeed(args []string) error {
 			return speedHTTP(attemptCtx, socksAddr, *speedURL, *maxBytes, *timeout)
 		},
 	)
+	rep.Attempts = attempt
 	if err != nil {
-		return fmt.Errorf("speed request failed: %w\n%s", err, coreLogTails(started))
+		return fail(coreFailure(stageRequest, "speed request failed", err, started))
 	}
 	mbps := (float64(bytesRead) * 8) / elapsed.Seconds() / 1_000_000
+	rep.Bytes = bytesRead
+	rep.ElapsedMS = elapsed.Milliseconds()
+	rep.Mbps = math.Round(mbps*100) / 100
 	if partialErr != nil {
-		fmt.Printf("status=partial protocol=%s bytes=%d elapsed_ms=%d mbps=%.2f attempts=%d error=%q\n",
-			prov.Name(), bytesRead, elapsed.Milliseconds(), mbps, attempt, partialErr.Error())
-		return nil
+		rep.Status = "partial"
+		rep.Error = errorInfoOf(withStage(stageRequest, partialErr))
 	}
-	fmt.Printf("status=ok protocol=%s bytes=%d elapsed_ms=%d mbps=%.2f attempts=%d\n",
-		prov.Name(), bytesRead, elapsed.Milliseconds(), mbps, attempt)
-	return nil
-}
-
-func runInstallCore(args []string) error {
-	fs := flag.NewFlagSet("install-core", flag.ContinueOnError)
-	repo := fs.String("repo", "XTLS/Xray-core", "GitHub repo owner/name")
-	version := fs.String("version", "latest", "release tag or latest")
-	dest := fs.String("dest", ".", "install directory")
-	force := fs.Bool("force", false, "overwrite existing binary")
-	timeout := fs.Duration("timeout", 2*time.Minute, "download/install timeout")
-	if err := fs.Parse(args); err != nil {
-		return err
+	if format != formatText {
+		return writeJSON(os.Stdout, rep)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
-	defer cancel()
-
-	path, tag, err := installer.Install(ctx, installer.Options{
-		Repo:    *repo,
-		Version: *version,
-		DestDir: *dest,
-		Force:   *force,
-	})
-	if err != nil {
-		return err
+	if partialErr != nil {
+		fmt.Printf("status=partial protocol=%s bytes=%d elapsed_ms=%d mbps=%.2f attempts=%d error=%q%s\n",
+			prov.Name(), bytesRead, elapsed.Milliseconds(), mbps, attempt, partialErr.Error(), remarkField(prov))
+		return nil
 	}
-	fmt.Printf("status=ok repo=%s version=%s installed=%s\n", *repo, tag, path)
+	fmt.Printf("status=ok protocol=%s bytes=%d elapsed_ms=%d mbps=%.2f attempts=%d%s\n",
+		prov.Name(), bytesRead, elapsed.Milliseconds(), mbps, attempt, remarkField(prov))
 	return nil
 }
 
 func runProxy(args []string, defaultInbound string) error {
 	fs := flag.NewFlagSet("proxy", flag.ContinueOnError)
-	uri := fs.String("uri", "", "VLESS/VMess URI")
+	uri := fs.String("uri", "", "share link URI")
 	corePath := fs.String("core", "", "core binary path")
 	inbound := fs.String("inbound", defaultInbound, "inbound protocol: socks|http")
 	localPort := fs.Int("local-port", 0, "local proxy listen port")
+	listen := fs.String("listen", "127.0.0.1", "listen address (0.0.0.0 or [::] to share on the LAN)")
+	auth := fs.String("auth", "", "require user:pass on the proxy")
 	printRequests := fs.Bool("print-requests", false, "stream core log lines")
 	noTraffic := fs.Bool("no-traffic", false, "disable live traffic counters")
 	trafficInterval := fs.Duration("traffic-interval", 2*time.Second, "traffic refresh interval")
 	timeout := fs.Duration("timeout", 20*time.Second, "startup timeout")
+	keepTemp := fs.Bool("keep-temp", false, "keep the core's temp config and logs after exit (for debugging)")
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
@@ -297,15 +424,19 @@ func runProxy(args []string, defaultInbound string) error {
 		return errors.New("--inbound must be socks or http")
 	}
 	if *localPort == 0 {
-		if *inbound == "http" {
-			*localPort = 8080
-		} else {
-			*localPort = 1080
-		}
+		*localPort = defaultLocalPort(*inbound)
 	}
 	if *localPort <= 0 || *localPort > 65535 {
 		return errors.New("--local-port must be in range 1..65535")
 	}
+	listenHost, loopback, err := parseListenHost(*listen)
+	if err != nil {
+		return err
+	}
+	user, password, err := parseAuth(*auth)
+	if err != nil {
+		return err
+	}
 
 	resolvedCore, err := resolveCorePath(*corePath)
 	if err != nil {
@@ -316,6 +447,12 @@ func runProxy(args []string, defaultInbound string) error {
 	if err != nil {
 		return err
 	}
+	if err := checkCoreProtocol(resolvedCore, prov); err != nil {
+		return err
+	}
+	if !loopback {
+		warnExposed(os.Stderr, listenHost, user != "")
+	}
 	showTraffic := !*noTraffic
 	if *trafficInterval < 200*time.Millisecond {
 		*trafficInterval = 200 * time.Millisecond
@@ -326,37 +463,35 @@ func runProxy(args []string, defaultInbound string) error {
 		return err
 	}
 
-	corePort := *localPort
-	if showTraffic {
-		corePort = randomPort()
-		if corePort == *localPort {
-			corePort = randomPort()
-		}
-	}
-
-	logLevel := "warning"
-	if *printRequests {
-		logLevel = "info"
-	}
+	// The relay owns the listen address while the core listens on a free
+	// loopback port behind it, a new one after every restart; binding the
+	// relay first keeps the core off its port.
+	listenAddr := net.JoinHostPort(listenHost, strconv.Itoa(*localPort))
 	r := core.Runner{
 		CorePath:        resolvedCore,
-		Port:            corePort,
+		Port:            *localPort,
 		Timeout:         *timeout,
 		InboundProtocol: *inbound,
-		LogLevel:        logLevel,
+		LogLevel:        "warning",
+		Listen:          listenHost,
+		User:            user,
+		Password:        password,
+		KeepTempDir:     *keepTemp,
 	}
-	started, err := r.Start(context.Background(), outbound)
-	if err != nil {
-		return err
+	if showTraffic {
+		r.Port, r.Listen = 0, ""
 	}
-	defer started.Stop()
-
-	coreAddr := fmt.Sprintf("127.0.0.1:%d", corePort)
-	listenAddr := fmt.Sprintf("127.0.0.1:%d", *localPort)
-	startupCtx, cancel := context.WithTimeout(context.Background(), *timeout)
-	defer cancel()
-	if err := waitSocks(startupCtx, coreAddr, *timeout); err != nil {
-		return fmt.Errorf("core did not become ready: %w\n%s", err, coreLogTails(started))
+	if *printRequests {
+		r.LogLevel = "info"
+	}
+	status := &coreStatus{}
+	sup := &core.Supervisor{Runner: r, Outbound: outbound, OnEvent: status.handle}
+	coreAddr := func() string {
+		cur := sup.Current()
+		if cur == nil {
+			return ""
+		}
+		return fmt.Sprintf("127.0.0.1:%d", cur.Routes[0].Port)
 	}
 
 	stopRelay := func() {}
@@ -371,7 +506,17 @@ func runProxy(args []string, defaultInbound string) error {
 	}
 	defer stopRelay()
 
-	fmt.Printf("status=ok mode=proxy inbound=%s protocol=%s listen=%s\n", *inbound, prov.Name(), listenAddr)
+	started, err := sup.Start(context.Background())
+	if started == nil {
+		return err
+	}
+	defer sup.Stop()
+	warnDowngraded(os.Stderr, started)
+	if err != nil {
+		return coreNotReadyError(err, started, outbound)
+	}
+
+	fmt.Printf("status=ok mode=proxy inbound=%s protocol=%s listen=%s%s\n", *inbound, prov.Name(), listenAddr, remarkField(prov))
 	fmt.Println("running until interrupted (Ctrl+C)")
 	if *printRequests {
 		fmt.Printf("log=%s\n", started.LogPath)
@@ -390,7 +535,7 @@ func runProxy(args []string, defaultInbound string) error {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			streamLog(stopLog, started.AccessLogPath)
+			streamLog(stopLog, func() string { return sup.Current().AccessLogPath })
 		}()
 	}
 	if showTraffic {
@@ -399,13 +544,17 @@ func runProxy(args []string, defaultInbound string) error {
 			Listen:   listenAddr,
 			Inbound:  *inbound,
 			Protocol: prov.Name(),
+			Remark:   provider.LabelOf(prov),
 			CoreAddr: coreAddr,
 			Started:  time.Now(),
+			Core:     status,
 		}
 		go func() {
 			defer wg.Done()
 			if isInteractiveTTY() && !*printRequests {
+				status.quiet.Store(true)
 				if err := meter.runTUI(stopTraffic, meta, *trafficInterval); err != nil {
+					status.quiet.Store(false)
 					fmt.Fprintf(os.Stderr, "tui failed, falling back to line output: %v\n", err)
 					meter.runLine(stopTraffic, *trafficInterval)
 				}
@@ -421,8 +570,51 @@ func runProxy(args []string, defaultInbound string) error {
 	return nil
 }
 
-func streamLog(stop <-chan struct{}, path string) {
+func defaultLocalPort(inbound string) int {
+	if inbound == "http" {
+		return 8080
+	}
+	return 1080
+}
+
+// parseListenHost checks a --listen address and reports whether it only
+// accepts connections from this machine.
+func parseListenHost(v string) (string, bool, error) {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(v), "["), "]")
+	if host == "localhost" {
+		return "127.0.0.1", true, nil
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", false, fmt.Errorf("--listen must be an IP address, got %q", v)
+	}
+	return host, ip.IsLoopback(), nil
+}
+
+func parseAuth(v string) (string, string, error) {
+	if v == "" {
+		return "", "", nil
+	}
+	user, password, ok := strings.Cut(v, ":")
+	if !ok || user == "" {
+		return "", "", errors.New("--auth must be user:pass")
+	}
+	return user, password, nil
+}
+
+// warnExposed is printed whenever the proxy listens beyond loopback.
+func warnExposed(w io.Writer, host string, auth bool) {
+	fmt.Fprintf(w, "warning: listening on %s; other hosts on the network can use this proxy and its node\n", host)
+	if !auth {
+		fmt.Fprintln(w, "warning: no --auth set, so anyone who can reach the port can use it")
+	}
+}
+
+// streamLog follows the log file named by path, which changes when the
+// supervisor restarts the core.
+func streamLog(stop <-chan struct{}, path func() string) {
 	var offset int64
+	var current string
 	for {
 		select {
 		case <-stop:
@@ -430,7 +622,10 @@ func streamLog(stop <-chan struct{}, path string) {
 		case <-time.After(500 * time.Millisecond):
 		}
 
-		f, err := os.Open(path)
+		if p := path(); p != current {
+			current, offset = p, 0
+		}
+		f, err := os.Open(current)
 		if err != nil {
 			continue
 		}
@@ -456,11 +651,73 @@ func streamLog(stop <-chan struct{}, path string) {
 	}
 }
 
+// remarkField renders the provider's remark as a trailing key=value field
+// for line output, or "" when the link has no remark.
+func remarkField(prov provider.Provider) string {
+	label := provider.LabelOf(prov)
+	if label == "" {
+		return ""
+	}
+	return fmt.Sprintf(" remark=%q", label)
+}
+
+// checkCoreProtocol rejects links the resolved core cannot run before a config
+// is ever written, using the detected core's capabilities. When the core
+// cannot be detected only the binary name is checked: hysteria outbounds
+// only exist in Xray.
+func checkCoreProtocol(corePath string, prov provider.Provider) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	info, err := core.Detect(ctx, corePath)
+	if err != nil || !info.Known() {
+		if prov.Name() == "hysteria2" && !strings.Contains(strings.ToLower(filepath.Base(corePath)), "xray") {
+			return fmt.Errorf("%s links require an Xray core with hysteria support, got %s", prov.Name(), corePath)
+		}
+		return nil
+	}
+	outbound, err := prov.Outbound()
+	if err != nil {
+		// Reported with the input stage once the caller builds the outbound.
+		return nil
+	}
+	_, _, err = info.Adapt(outbound)
+	return err
+}
+
+// warnDowngraded notes features dropped from the config because the core is
+// too old for them.
+func warnDowngraded(w io.Writer, started *core.Started) {
+	for _, c := range started.Downgraded {
+		fmt.Fprintf(w, "warning: %s does not support %s; running without it\n", started.Core, c)
+	}
+}
+
+func coreNotReadyError(err error, started *core.Started, outbound map[string]any) error {
+	protocol, _ := outbound["protocol"].(string)
+	if protocol != "" && mentionsUnknownProtocol(started.ReadLogTail(), protocol) {
+		return coreFailure(stageReady, fmt.Sprintf("core does not support %q outbounds (upgrade the core)", protocol), err, started)
+	}
+	return coreFailure(stageReady, "core did not become ready", err, started)
+}
+
+func mentionsUnknownProtocol(logTail, protocol string) bool {
+	for _, line := range strings.Split(strings.ToLower(logTail), "\n") {
+		if strings.Contains(line, "unknown") && strings.Contains(line, strings.ToLower(protocol)) {
+			return true
+		}
+	}
+	return false
+}
+
 func coreLogTails(started *core.Started) string {
 	if started == nil {
 		return "core logs unavailable"
 	}
-	return fmt.Sprintf("core error log tail:\n%s\ncore access log tail:\n%s", started.ReadLogTail(), started.ReadAccessLogTail())
+	return formatLogTails(started.ReadLogTail(), started.ReadAccessLogTail())
+}
+
+func formatLogTails(errorLog, accessLog string) string {
+	return fmt.Sprintf("core error log tail:\n%s\ncore access log tail:\n%s", errorLog, accessLog)
 }
 
 type trafficMeter struct {
@@ -478,8 +735,100 @@ type dashboardMeta struct {
 	Listen   string
 	Inbound  string
 	Protocol string
-	CoreAddr string
+	Remark   string
+	CoreAddr func() string
 	Started  time.Time
+	Core     *coreStatus
+}
+
+func (d dashboardMeta) outboundLabel() string {
+	if d.Remark == "" {
+		return d.Protocol
+	}
+	return fmt.Sprintf("%s (%s)", tview.Escape(d.Remark), d.Protocol)
+}
+
+// coreStatus tracks the supervised core for the dashboard and prints its
+// lifecycle events as [core] lines unless quiet (while the TUI owns the
+// terminal).
+type coreStatus struct {
+	quiet atomic.Bool
+
+	mu       sync.Mutex
+	state    core.EventKind
+	pid      int
+	restarts int
+	lastExit string
+}
+
+func (s *coreStatus) handle(ev core.Event) {
+	s.mu.Lock()
+	s.state = ev.Kind
+	s.restarts = ev.Restarts
+	switch ev.Kind {
+	case core.EventStarted:
+		s.pid = ev.PID
+	case core.EventExited:
+		s.lastExit = exitSummary(ev)
+	}
+	s.mu.Unlock()
+
+	// The first start is already announced by status=ok.
+	if s.quiet.Load() || (ev.Kind == core.EventStarted && ev.Restarts == 0) {
+		return
+	}
+	writeCoreEvent(os.Stdout, ev)
+}
+
+func writeCoreEvent(w io.Writer, ev core.Event) {
+	switch ev.Kind {
+	case core.EventStarted:
+		fmt.Fprintf(w, "[core] status=started pid=%d restarts=%d\n", ev.PID, ev.Restarts)
+	case core.EventExited:
+		fmt.Fprintf(w, "[core] status=exited %s\n", exitSummary(ev))
+		for _, line := range lastLines(ev.LogTail, 5) {
+			fmt.Fprintf(w, "[core]   %s\n", line)
+		}
+	case core.EventRestarting:
+		fmt.Fprintf(w, "[core] status=restarting attempt=%d backoff=%s\n", ev.Restarts, ev.Backoff)
+	}
+}
+
+func exitSummary(ev core.Event) string {
+	out := fmt.Sprintf("code=%d", ev.ExitCode)
+	if ev.Err != nil {
+		out += fmt.Sprintf(" error=%q", ev.Err.Error())
+	}
+	return out
+}
+
+func lastLines(s string, n int) []string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// text renders the status for the dashboard's Proxy panel.
+func (s *coreStatus) text() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	state := "[green]running[white]"
+	switch s.state {
+	case core.EventExited:
+		state = "[red]exited[white]"
+	case core.EventRestarting:
+		state = "[yellow]restarting[white]"
+	}
+	out := fmt.Sprintf("%s (pid %d, restarts %d)", state, s.pid, s.restarts)
+	if s.lastExit != "" {
+		out += "\n[green]last exit:[white] " + tview.Escape(s.lastExit)
+	}
+	return out
 }
 
 func (m *trafficMeter) runLine(stop <-chan struct{}, interval time.Duration) {
@@ -526,7 +875,7 @@ func (m *trafficMeter) runTUI(stop <-chan struct{}, meta dashboardMeta, interval
 
 	root := tview.NewFlex().
 		SetDirection(tview.FlexRow).
-		AddItem(info, 8, 0, false).
+		AddItem(info, 9, 0, false).
 		AddItem(stats, 8, 0, false).
 		AddItem(hint, 3, 0, false)
 
@@ -538,8 +887,11 @@ func (m *trafficMeter) runTUI(stop <-chan struct{}, meta dashboardMeta, interval
 
 		infoText := fmt.Sprintf(
 			"[green]listen:[white] %s (%s)\n[green]outbound:[white] %s\n[green]core backend:[white] %s\n[green]uptime:[white] %s",
-			meta.Listen, meta.Inbound, meta.Protocol, meta.CoreAddr, time.Since(meta.Started).Truncate(time.Second),
+			meta.Listen, meta.Inbound, meta.outboundLabel(), meta.CoreAddr(), time.Since(meta.Started).Truncate(time.Second),
 		)
+		if meta.Core != nil {
+			infoText += "\n[green]core:[white] " + meta.Core.text()
+		}
 		statsText := fmt.Sprintf(
 			"[green]connections active:[white] %d    [green]total:[white] %d\n"+
 				"[green]uplink rate:[white] %s/s\n"+
@@ -578,7 +930,9 @@ func (m *trafficMeter) runTUI(stop <-chan struct{}, meta dashboardMeta, interval
 	return app.SetRoot(root, true).SetFocus(root).Run()
 }
 
-func startRelay(listenAddr, targetAddr string, meter *trafficMeter) (func(), error) {
+// startRelay forwards connections on listenAddr to target(), which is
+// looked up per connection so the core behind it can move.
+func startRelay(listenAddr string, target func() string, meter *trafficMeter) (func(), error) {
 	ln, err := net.Listen("tcp", listenAddr)
 	if err != nil {
 		return nil, err
@@ -601,7 +955,7 @@ func startRelay(listenAddr, targetAddr string, meter *trafficMeter) (func(), err
 			wg.Add(1)
 			go func(c net.Conn) {
 				defer wg.Done()
-				relayConn(c, targetAddr, meter)
+				relayConn(c, target(), meter)
 			}(conn)
 		}
 	}()
@@ -669,7 +1023,11 @@ func humanBytes(n uint64) string {
 }
 
 func isInteractiveTTY() bool {
-	info, err := os.Stdout.Stat()
+	return isTerminal(os.Stdout)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
 	if err != nil {
 		return false
 	}
@@ -699,25 +1057,6 @@ func addSaturating(counter *atomic.Uint64, delta uint64) {
 	}
 }
 
-func waitSocks(ctx context.Context, socksAddr string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	for {
-		if time.Now().After(deadline) {
-			return errors.New("timeout")
-		}
-		conn, err := net.DialTimeout("tcp", socksAddr, 500*time.Millisecond)
-		if err == nil {
-			_ = conn.Close()
-			return nil
-		}
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(200 * time.Millisecond):
-		}
-	}
-}
-
 func probeHTTP(ctx context.Context, socksAddr, rawURL string, timeout time.Duration) (time.Duration, int, int64, error) {
 	client := httpClientThroughSocks(socksAddr, timeout)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
@@ -819,11 +1158,6 @@ func httpClientThroughSocks(socksAddr string, timeout time.Duration) *http.Clien
 	}
 }
 
-func randomPort() int {
-	r := rand.New(rand.NewSource(time.Now().UnixNano()))
-	return 20000 + r.Intn(20000)
-}
-
 func resolveCorePath(flagPath string) (string, error) {
 	// Explicit path always wins.
 	if strings.TrimSpace(flagPath) != "" {
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"proxy-node/internal/core"
+	"proxy-node/internal/provider"
 )
 
 func TestDefaultProbeURL(t *testing.T) {
@@ -144,3 +146,126 @@ func TestSpeedHTTPWithRetries_ReturnsPartialOnReadError(t *testing.T) {
 		t.Fatalf("elapsed = %v, want 200ms", elapsed)
 	}
 }
+
+func TestCheckCoreProtocol_Hysteria2RequiresXray(t *testing.T) {
+	t.Parallel()
+
+	hy := &provider.Hysteria2{Address: "example.com", Port: 443, Auth: "secret"}
+	if err := checkCoreProtocol("/usr/local/bin/v2ray", hy); err == nil {
+		t.Fatal("checkCoreProtocol(v2ray) error = nil, want unsupported core error")
+	}
+	if err := checkCoreProtocol("/usr/local/bin/xray", hy); err != nil {
+		t.Fatalf("checkCoreProtocol(xray) error = %v, want nil", err)
+	}
+	if err := checkCoreProtocol("/usr/local/bin/v2ray", &provider.VLESS{}); err != nil {
+		t.Fatalf("checkCoreProtocol(v2ray, vless) error = %v, want nil", err)
+	}
+}
+
+func TestCoreNotReadyError_UnknownProtocol(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	errorPath := filepath.Join(dir, "core.log")
+	logLine := "Failed to start: infra/conf: failed to load outbound config: unknown config id: hysteria"
+	if err := os.WriteFile(errorPath, []byte(logLine), 0o600); err != nil {
+		t.Fatalf("WriteFile(error log) error = %v", err)
+	}
+
+	err := coreNotReadyError(errors.New("timeout"), &core.Started{LogPath: errorPath}, map[string]any{"protocol": "hysteria"})
+	if !strings.Contains(err.Error(), `does not support "hysteria"`) {
+		t.Fatalf("coreNotReadyError() = %q, want unsupported protocol message", err)
+	}
+}
+
+func TestRemarkField(t *testing.T) {
+	t.Parallel()
+
+	if got := remarkField(&provider.Trojan{Remark: "🚀 node 1"}); got != ` remark="🚀 node 1"` {
+		t.Fatalf("remarkField() = %q", got)
+	}
+	if got := remarkField(&provider.Trojan{}); got != "" {
+		t.Fatalf("remarkField(empty) = %q, want empty", got)
+	}
+}
+
+func TestWriteCoreEvent(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		ev   core.Event
+		want string
+	}{
+		{core.Event{Kind: core.EventStarted, PID: 42, Restarts: 2}, "[core] status=started pid=42 restarts=2\n"},
+		{core.Event{Kind: core.EventRestarting, Restarts: 3, Backoff: 4 * time.Second}, "[core] status=restarting attempt=3 backoff=4s\n"},
+		{
+			core.Event{Kind: core.EventExited, ExitCode: 23, LogTail: "a\nb\nc\nd\ne\nfailed to listen\n"},
+			"[core] status=exited code=23\n[core]   b\n[core]   c\n[core]   d\n[core]   e\n[core]   failed to listen\n",
+		},
+		{core.Event{Kind: core.EventExited, ExitCode: -1, Err: errors.New("no such file")}, "[core] status=exited code=-1 error=\"no such file\"\n"},
+	}
+	for _, c := range cases {
+		var b strings.Builder
+		writeCoreEvent(&b, c.ev)
+		if b.String() != c.want {
+			t.Fatalf("writeCoreEvent(%+v) = %q, want %q", c.ev, b.String(), c.want)
+		}
+	}
+}
+
+func TestParseListenHost(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		in       string
+		host     string
+		loopback bool
+		ok       bool
+	}{
+		{"127.0.0.1", "127.0.0.1", true, true},
+		{"localhost", "127.0.0.1", true, true},
+		{"[::1]", "::1", true, true},
+		{"0.0.0.0", "0.0.0.0", false, true},
+		{"[::]", "::", false, true},
+		{"192.168.1.10", "192.168.1.10", false, true},
+		{"example.com", "", false, false},
+		{"0.0.0.0:1080", "", false, false},
+	}
+	for _, c := range cases {
+		host, loopback, err := parseListenHost(c.in)
+		if (err == nil) != c.ok || host != c.host || loopback != c.loopback {
+			t.Fatalf("parseListenHost(%q) = %q, %v, %v", c.in, host, loopback, err)
+		}
+	}
+}
+
+func TestParseAuth(t *testing.T) {
+	t.Parallel()
+
+	if user, pass, err := parseAuth("me:s3:cret"); err != nil || user != "me" || pass != "s3:cret" {
+		t.Fatalf("parseAuth() = %q, %q, %v", user, pass, err)
+	}
+	if user, _, err := parseAuth(""); err != nil || user != "" {
+		t.Fatalf("parseAuth(empty) = %q, %v", user, err)
+	}
+	for _, v := range []string{"me", ":pass"} {
+		if _, _, err := parseAuth(v); err == nil {
+			t.Fatalf("parseAuth(%q) error = nil", v)
+		}
+	}
+}
+
+func TestWarnExposed(t *testing.T) {
+	t.Parallel()
+
+	var b strings.Builder
+	warnExposed(&b, "0.0.0.0", false)
+	if !strings.Contains(b.String(), "listening on 0.0.0.0") || !strings.Contains(b.String(), "--auth") {
+		t.Fatalf("warnExposed() = %q", b.String())
+	}
+	b.Reset()
+	warnExposed(&b, "::", true)
+	if strings.Contains(b.String(), "--auth") {
+		t.Fatalf("warnExposed(auth) = %q, want no --auth hint", b.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"proxy-node/internal/provider"
+)
+
+func runNormalize(args []string) error {
+	fs := flag.NewFlagSet("normalize", flag.ContinueOnError)
+	var uris, subs stringList
+	fs.Var(&uris, "uri", "share link URI (repeatable)")
+	fs.Var(&subs, "sub", "subscription URL, file path, or - for stdin (repeatable)")
+	keepDuplicates := fs.Bool("keep-duplicates", false, "print every link even if it was already printed")
+	timeout := fs.Duration("timeout", 20*time.Second, "subscription fetch timeout")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	uris = append(uris, fs.Args()...)
+	if len(uris) == 0 && len(subs) == 0 {
+		return errors.New("at least one --uri or --sub is required")
+	}
+
+	targets, err := collectTargets(uris, subs, *timeout, os.Stdin)
+	if err != nil {
+		return err
+	}
+	normalizeTargets(os.Stdout, os.Stderr, targets, !*keepDuplicates)
+	return nil
+}
+
+// normalizeTargets writes one canonical link per line to out, keeping each
+// node's remark; duplicates are detected on the link without the remark.
+// Failures and the summary go to errOut so out stays a valid plain-text feed.
+func normalizeTargets(out, errOut io.Writer, targets []batchTarget, dedupe bool) {
+	seen := make(map[string]bool, len(targets))
+	var unique, duplicates, failed int
+	for _, t := range targets {
+		err := t.Err
+		key, link := "", ""
+		if err == nil {
+			key, err = provider.CanonicalURI(t.Provider)
+		}
+		if err == nil {
+			link, err = provider.URI(t.Provider)
+		}
+		if err != nil {
+			failed++
+			fmt.Fprintf(errOut, "status=error source=%s error=%q\n", t.Source, firstLine(err.Error()))
+			continue
+		}
+		if seen[key] {
+			duplicates++
+			if dedupe {
+				continue
+			}
+		} else {
+			unique++
+			seen[key] = true
+		}
+		fmt.Fprintln(out, link)
+	}
+	fmt.Fprintf(errOut, "status=done total=%d unique=%d duplicates=%d failed=%d\n", len(targets), unique, duplicates, failed)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTargets_Dedupes(t *testing.T) {
+	t.Parallel()
+
+	targets, err := collectTargets([]string{
+		"trojan://secret@example.com:443?type=ws&path=%2Fws&security=tls",
+		"trojan://secret@example.com:443?security=tls&path=%2Fws&type=ws#other-name",
+		"trojan://secret@example.com:8443",
+		"broken://x",
+	}, nil, time.Second, nil)
+	if err != nil {
+		t.Fatalf("collectTargets() error = %v", err)
+	}
+
+	var out, errOut bytes.Buffer
+	normalizeTargets(&out, &errOut, targets, true)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d links, want 2: %q", len(lines), out.String())
+	}
+	if lines[0] != "trojan://secret@example.com:443?path=%2Fws&type=ws" {
+		t.Fatalf("lines[0] = %q", lines[0])
+	}
+	if lines[1] != "trojan://secret@example.com:8443" {
+		t.Fatalf("lines[1] = %q", lines[1])
+	}
+	if !strings.Contains(errOut.String(), "status=done total=4 unique=2 duplicates=1 failed=1") {
+		t.Fatalf("summary = %q", errOut.String())
+	}
+	if !strings.Contains(errOut.String(), "source=uri:4") {
+		t.Fatalf("errOut = %q, want failure for uri:4", errOut.String())
+	}
+}
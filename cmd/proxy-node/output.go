@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"proxy-node/internal/core"
+	"proxy-node/internal/installer"
+)
+
+type outputFormat string
+
+const (
+	formatText   outputFormat = "text"
+	formatJSON   outputFormat = "json"
+	formatNDJSON outputFormat = "ndjson"
+)
+
+func parseOutputFormat(v string, batch bool) (outputFormat, error) {
+	switch f := outputFormat(strings.ToLower(strings.TrimSpace(v))); f {
+	case "", formatText:
+		return formatText, nil
+	case formatJSON:
+		return formatJSON, nil
+	case formatNDJSON:
+		if batch {
+			return formatNDJSON, nil
+		}
+		return "", errors.New("--output ndjson is only supported by batch commands")
+	default:
+		if batch {
+			return "", fmt.Errorf("--output must be text, json or ndjson, got %q", v)
+		}
+		return "", fmt.Errorf("--output must be text or json, got %q", v)
+	}
+}
+
+// Error stages reported in JSON output.
+const (
+	stageInput   = "input"
+	stageCore    = "core"
+	stageConfig  = "config"
+	stageReady   = "ready"
+	stageRequest = "request"
+	stageInstall = "install"
+)
+
+// commandError attaches a stage and, once a core was running, its log tails
+// to an error. Its text form matches what the CLI has always printed.
+type commandError struct {
+	Stage     string
+	Msg       string
+	Err       error
+	HasLogs   bool
+	ErrorLog  string
+	AccessLog string
+}
+
+func (e *commandError) Error() string {
+	msg := e.message()
+	if !e.HasLogs {
+		return msg
+	}
+	return msg + "\n" + formatLogTails(e.ErrorLog, e.AccessLog)
+}
+
+func (e *commandError) Unwrap() error { return e.Err }
+
+func (e *commandError) message() string {
+	if e.Msg == "" {
+		return e.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", e.Msg, e.Err)
+}
+
+func withStage(stage string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var ce *commandError
+	if errors.As(err, &ce) {
+		return err
+	}
+	var cfgErr *core.ConfigError
+	if errors.As(err, &cfgErr) {
+		stage = stageConfig
+	}
+	return &commandError{Stage: stage, Err: err}
+}
+
+func coreFailure(stage, msg string, err error, started *core.Started) error {
+	ce := &commandError{Stage: stage, Msg: msg, Err: err}
+	if started != nil {
+		ce.HasLogs = true
+		ce.ErrorLog = started.ReadLogTail()
+		ce.AccessLog = started.ReadAccessLogTail()
+	}
+	return ce
+}
+
+// reportedError marks an error whose details were already written to stdout
+// as JSON; main only sets the exit code for it.
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string { return e.err.Error() }
+
+func (e *reportedError) Unwrap() error { return e.err }
+
+type errorInfo struct {
+	Stage         string `json:"stage,omitempty"`
+	Message       string `json:"message"`
+	CoreErrorLog  string `json:"core_error_log,omitempty"`
+	CoreAccessLog string `json:"core_access_log,omitempty"`
+}
+
+func errorInfoOf(err error) *errorInfo {
+	if err == nil {
+		return nil
+	}
+	var ce *commandError
+	if errors.As(err, &ce) {
+		return &errorInfo{
+			Stage:         ce.Stage,
+			Message:       ce.message(),
+			CoreErrorLog:  ce.ErrorLog,
+			CoreAccessLog: ce.AccessLog,
+		}
+	}
+	return &errorInfo{Message: err.Error()}
+}
+
+type probeReport struct {
+	Status    string     `json:"status"`
+	Command   string     `json:"command"`
+	Source    string     `json:"source,omitempty"`
+	Protocol  string     `json:"protocol,omitempty"`
+	Address   string     `json:"address,omitempty"`
+	Remark    string     `json:"remark,omitempty"`
+	LatencyMS int64      `json:"latency_ms"`
+	Code      int        `json:"code"`
+	Bytes     int64      `json:"bytes"`
+	Error     *errorInfo `json:"error,omitempty"`
+}
+
+type speedReport struct {
+	Status    string     `json:"status"`
+	Command   string     `json:"command"`
+	Protocol  string     `json:"protocol,omitempty"`
+	Address   string     `json:"address,omitempty"`
+	Remark    string     `json:"remark,omitempty"`
+	Bytes     int64      `json:"bytes"`
+	ElapsedMS int64      `json:"elapsed_ms"`
+	Mbps      float64    `json:"mbps"`
+	Attempts  int        `json:"attempts"`
+	Error     *errorInfo `json:"error,omitempty"`
+}
+
+type installReport struct {
+	Status      string     `json:"status"`
+	Command     string     `json:"command"`
+	Repo        string     `json:"repo,omitempty"`
+	Source      string     `json:"source,omitempty"`
+	Version     string     `json:"version,omitempty"`
+	Installed   string     `json:"installed,omitempty"`
+	CoreVersion string     `json:"core_version,omitempty"`
+	Previous    string     `json:"previous,omitempty"`
+	UpToDate    bool       `json:"up_to_date,omitempty"`
+	Files       []string   `json:"files,omitempty"`
+	Error       *errorInfo `json:"error,omitempty"`
+}
+
+type releaseListReport struct {
+	Status   string              `json:"status"`
+	Command  string              `json:"command"`
+	Repo     string              `json:"repo"`
+	Releases []installer.Release `json:"releases"`
+	Error    *errorInfo          `json:"error,omitempty"`
+}
+
+type linkReport struct {
+	Status   string     `json:"status"`
+	Command  string     `json:"command"`
+	Line     int        `json:"line"`
+	Protocol string     `json:"protocol,omitempty"`
+	Address  string     `json:"address,omitempty"`
+	Remark   string     `json:"remark,omitempty"`
+	Error    *errorInfo `json:"error,omitempty"`
+}
+
+// batchSummary counts the entries of a batch. Failed is always Total-OK:
+// every entry that did not succeed, whether it failed to parse or to probe.
+type batchSummary struct {
+	Total  int `json:"total"`
+	OK     int `json:"ok"`
+	Failed int `json:"failed"`
+}
+
+type batchDocument[T any] struct {
+	Results []T          `json:"results"`
+	Summary batchSummary `json:"summary"`
+}
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
+
+// reportFailure renders err as a JSON report for non-text formats. In text
+// mode err is returned untouched so main prints it as before.
+func reportFailure(format outputFormat, rep any, err error) error {
+	if format == formatText {
+		return err
+	}
+	if werr := writeJSON(os.Stdout, rep); werr != nil {
+		return werr
+	}
+	return &reportedError{err: err}
+}
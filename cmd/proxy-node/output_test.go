@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy-node/internal/core"
+	"proxy-node/internal/provider"
+)
+
+func TestParseOutputFormat(t *testing.T) {
+	t.Parallel()
+
+	if f, err := parseOutputFormat("", false); err != nil || f != formatText {
+		t.Fatalf("parseOutputFormat(\"\") = %q, %v, want text", f, err)
+	}
+	if f, err := parseOutputFormat("JSON", false); err != nil || f != formatJSON {
+		t.Fatalf("parseOutputFormat(JSON) = %q, %v, want json", f, err)
+	}
+	if _, err := parseOutputFormat("ndjson", false); err == nil {
+		t.Fatal("parseOutputFormat(ndjson, single) error = nil, want error")
+	}
+	if f, err := parseOutputFormat("ndjson", true); err != nil || f != formatNDJSON {
+		t.Fatalf("parseOutputFormat(ndjson, batch) = %q, %v, want ndjson", f, err)
+	}
+	if _, err := parseOutputFormat("yaml", true); err == nil {
+		t.Fatal("parseOutputFormat(yaml) error = nil, want error")
+	}
+}
+
+func TestCoreFailure_TextAndStructured(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	errorPath := filepath.Join(dir, "core.log")
+	accessPath := filepath.Join(dir, "access.log")
+	if err := os.WriteFile(errorPath, []byte("err-line"), 0o600); err != nil {
+		t.Fatalf("WriteFile(error log) error = %v", err)
+	}
+	if err := os.WriteFile(accessPath, []byte("acc-line"), 0o600); err != nil {
+		t.Fatalf("WriteFile(access log) error = %v", err)
+	}
+	started := &core.Started{LogPath: errorPath, AccessLogPath: accessPath}
+
+	cause := errors.New("EOF")
+	err := coreFailure(stageRequest, "probe request failed", cause, started)
+	if !errors.Is(err, cause) {
+		t.Fatalf("errors.Is(err, cause) = false")
+	}
+	want := "probe request failed: EOF\n" + coreLogTails(started)
+	if err.Error() != want {
+		t.Fatalf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	info := errorInfoOf(err)
+	if info.Stage != stageRequest || info.Message != "probe request failed: EOF" {
+		t.Fatalf("errorInfoOf() = %+v, want request stage and short message", info)
+	}
+	if info.CoreErrorLog != "err-line" || info.CoreAccessLog != "acc-line" {
+		t.Fatalf("errorInfoOf() logs = %q/%q, want err-line/acc-line", info.CoreErrorLog, info.CoreAccessLog)
+	}
+}
+
+func TestWriteProbeReports_NDJSON(t *testing.T) {
+	t.Parallel()
+
+	prov, err := provider.FromURI("trojan://secret@example.com:443")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	outcomes := []probeOutcome{
+		{Target: batchTarget{Source: "uri:1", Provider: prov}, Result: probeResult{Latency: 120 * time.Millisecond, Code: 200, Bytes: 10}},
+		{Target: batchTarget{Source: "uri:2"}, Err: withStage(stageInput, errors.New("unsupported scheme"))},
+	}
+
+	var buf bytes.Buffer
+	if err := writeProbeReports(&buf, formatNDJSON, outcomes); err != nil {
+		t.Fatalf("writeProbeReports() error = %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2: %q", len(lines), buf.String())
+	}
+
+	var first, second probeReport
+	if err := json.Unmarshal([]byte(lines[0]), &first); err != nil {
+		t.Fatalf("Unmarshal(line 1) error = %v", err)
+	}
+	if first.Status != "ok" || first.Protocol != "trojan" || first.Address != "example.com:443" || first.LatencyMS != 120 {
+		t.Fatalf("line 1 = %+v, want ok trojan report", first)
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatalf("Unmarshal(line 2) error = %v", err)
+	}
+	if second.Status != "error" || second.Error == nil || second.Error.Stage != stageInput {
+		t.Fatalf("line 2 = %+v, want input-stage error", second)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"proxy-node/internal/core"
+	"proxy-node/internal/provider"
+)
+
+const defaultProbeWorkers = 8
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+type batchTarget struct {
+	Source   string
+	Raw      string
+	Provider provider.Provider
+	Err      error
+}
+
+type probeOutcome struct {
+	Target batchTarget
+	Result probeResult
+	Err    error
+}
+
+func runProbeAll(args []string) error {
+	fs := flag.NewFlagSet("probe-all", flag.ContinueOnError)
+	var uris, subs stringList
+	fs.Var(&uris, "uri", "share link URI (repeatable)")
+	fs.Var(&subs, "sub", "subscription URL, file path, or - for stdin (repeatable)")
+	corePath := fs.String("core", "", "core binary path")
+	probeURL := fs.String("url", defaultProbeURL, "probe URL")
+	workers := fs.Int("workers", defaultProbeWorkers, "number of concurrent cores")
+	timeout := fs.Duration("timeout", 20*time.Second, "per-node probe timeout")
+	singleCore := fs.Bool("single-core", false, "serve every node from one core process")
+	output := fs.String("output", "text", "output format: text|json|ndjson")
+	keepTemp := fs.Bool("keep-temp", false, "keep the core's temp config and logs after exit (for debugging)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, true)
+	if err != nil {
+		return err
+	}
+	uris = append(uris, fs.Args()...)
+	if len(uris) == 0 && len(subs) == 0 {
+		return errors.New("at least one --uri or --sub is required")
+	}
+	if *workers < 1 {
+		return errors.New("--workers must be >= 1")
+	}
+	resolvedCore, err := resolveCorePath(*corePath)
+	if err != nil {
+		return err
+	}
+
+	targets, err := collectTargets(uris, subs, *timeout, os.Stdin)
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		return errors.New("no share links to probe")
+	}
+
+	var outcomes []probeOutcome
+	if *singleCore {
+		outcomes = probeAllShared(resolvedCore, targets, *workers, *probeURL, *timeout, *keepTemp)
+	} else {
+		outcomes = probeAll(resolvedCore, targets, *workers, *probeURL, *timeout, *keepTemp)
+	}
+	sortProbeOutcomes(outcomes)
+	if format == formatText {
+		printProbeTable(os.Stdout, outcomes)
+		return nil
+	}
+	return writeProbeReports(os.Stdout, format, outcomes)
+}
+
+// collectTargets expands --uri values and subscription sources into one
+// ordered list. Parse failures are kept so they show up in the results.
+func collectTargets(uris, subs []string, timeout time.Duration, stdin io.Reader) ([]batchTarget, error) {
+	stdinSubs := 0
+	for _, src := range subs {
+		if src == "-" {
+			stdinSubs++
+		}
+	}
+	if stdinSubs > 1 {
+		return nil, errors.New("--sub - reads stdin and can only be given once")
+	}
+	var targets []batchTarget
+	for i, raw := range uris {
+		raw = strings.TrimSpace(raw)
+		prov, err := provider.FromURI(raw)
+		targets = append(targets, batchTarget{Source: fmt.Sprintf("uri:%d", i+1), Raw: raw, Provider: prov, Err: withStage(stageInput, err)})
+	}
+	for _, src := range subs {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		body, err := loadSubscription(ctx, src, stdin)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		name := src
+		if name == "-" {
+			name = "stdin"
+		}
+		for _, e := range provider.ParseSubscription(body) {
+			targets = append(targets, batchTarget{
+				Source:   fmt.Sprintf("%s:%d", name, e.Line),
+				Raw:      e.Raw,
+				Provider: e.Provider,
+				Err:      withStage(stageInput, e.Err),
+			})
+		}
+	}
+	return targets, nil
+}
+
+// probeAll probes targets with a fixed pool of workers, each running one core
+// at a time on a free local port.
+func probeAll(corePath string, targets []batchTarget, workers int, probeURL string, timeout time.Duration, keepTemp bool) []probeOutcome {
+	outcomes := make([]probeOutcome, len(targets))
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	runPool(len(targets), workers, func(_, i int) {
+		t := targets[i]
+		outcomes[i] = probeOutcome{Target: t}
+		if t.Err != nil {
+			outcomes[i].Err = t.Err
+			return
+		}
+		outcomes[i].Result, outcomes[i].Err = probeTarget(corePath, t.Provider, 0, probeURL, timeout, keepTemp)
+	})
+	return outcomes
+}
+
+// probeAllShared starts a single core with one inbound per target and probes
+// every inbound concurrently. A config the core rejects fails the whole batch.
+func probeAllShared(corePath string, targets []batchTarget, workers int, probeURL string, timeout time.Duration, keepTemp bool) []probeOutcome {
+	outcomes := make([]probeOutcome, len(targets))
+	var outbounds []map[string]any
+	var indexes []int
+	for i, t := range targets {
+		outcomes[i] = probeOutcome{Target: t}
+		if t.Err != nil {
+			outcomes[i].Err = t.Err
+			continue
+		}
+		if err := checkCoreProtocol(corePath, t.Provider); err != nil {
+			outcomes[i].Err = withStage(stageCore, err)
+			continue
+		}
+		ob, err := t.Provider.Outbound()
+		if err != nil {
+			outcomes[i].Err = withStage(stageInput, err)
+			continue
+		}
+		outbounds = append(outbounds, ob)
+		indexes = append(indexes, i)
+	}
+	if len(outbounds) == 0 {
+		return outcomes
+	}
+
+	failAll := func(err error) []probeOutcome {
+		for _, i := range indexes {
+			outcomes[i].Err = err
+		}
+		return outcomes
+	}
+
+	r := core.Runner{CorePath: corePath, Timeout: timeout, KeepTempDir: keepTemp}
+	started, err := r.StartManyReady(context.Background(), outbounds, timeout)
+	if started == nil {
+		return failAll(withStage(stageCore, err))
+	}
+	defer started.Stop()
+	warnDowngraded(os.Stderr, started)
+	if err != nil {
+		return failAll(coreFailure(stageReady, "core did not become ready", err, started))
+	}
+
+	if workers > len(indexes) {
+		workers = len(indexes)
+	}
+	runPool(len(indexes), workers, func(_, j int) {
+		i := indexes[j]
+		socksAddr := fmt.Sprintf("127.0.0.1:%d", started.Routes[j].Port)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		latency, code, n, err := probeHTTP(ctx, socksAddr, probeURL, timeout)
+		if err != nil {
+			outcomes[i].Err = withStage(stageRequest, fmt.Errorf("probe request failed: %w", err))
+			return
+		}
+		outcomes[i].Result = probeResult{Latency: latency, Code: code, Bytes: n}
+	})
+	return outcomes
+}
+
+// runPool calls fn for every job index in [0, n) from `workers` goroutines and
+// waits for all of them. fn also receives the worker number.
+func runPool(n, workers int, fn func(worker, job int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := range jobs {
+				fn(w, i)
+			}
+		}(w)
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+func probeTarget(corePath string, prov provider.Provider, port int, probeURL string, timeout time.Duration, keepTemp bool) (probeResult, error) {
+	if err := checkCoreProtocol(corePath, prov); err != nil {
+		return probeResult{}, withStage(stageCore, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return probeProvider(ctx, corePath, prov, port, probeURL, timeout, keepTemp)
+}
+
+// sortProbeOutcomes orders successes by latency and keeps failures last in
+// input order.
+func sortProbeOutcomes(outcomes []probeOutcome) {
+	sort.SliceStable(outcomes, func(i, j int) bool {
+		a, b := outcomes[i], outcomes[j]
+		if (a.Err == nil) != (b.Err == nil) {
+			return a.Err == nil
+		}
+		if a.Err != nil {
+			return false
+		}
+		return a.Result.Latency < b.Result.Latency
+	})
+}
+
+func printProbeTable(w io.Writer, outcomes []probeOutcome) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "RANK\tSTATUS\tLATENCY\tCODE\tPROTOCOL\tNAME\tSOURCE\tERROR")
+	var ok int
+	for i, o := range outcomes {
+		protocol, name := "-", "-"
+		if o.Target.Provider != nil {
+			protocol = o.Target.Provider.Name()
+			if label := provider.LabelOf(o.Target.Provider); label != "" {
+				name = strings.Join(strings.Fields(label), " ")
+			}
+		}
+		if o.Err != nil {
+			fmt.Fprintf(tw, "%d\tfail\t-\t-\t%s\t%s\t%s\t%s\n", i+1, protocol, name, o.Target.Source, firstLine(o.Err.Error()))
+			continue
+		}
+		ok++
+		fmt.Fprintf(tw, "%d\tok\t%dms\t%d\t%s\t%s\t%s\t\n", i+1, o.Result.Latency.Milliseconds(), o.Result.Code, protocol, name, o.Target.Source)
+	}
+	_ = tw.Flush()
+	fmt.Fprintf(w, "status=done total=%d ok=%d failed=%d\n", len(outcomes), ok, len(outcomes)-ok)
+}
+
+func outcomeReport(o probeOutcome) probeReport {
+	rep := probeReport{Status: "ok", Command: "probe-all", Source: o.Target.Source}
+	if o.Target.Provider != nil {
+		rep.Protocol = o.Target.Provider.Name()
+		rep.Remark = provider.LabelOf(o.Target.Provider)
+		rep.Address = provider.EndpointOf(o.Target.Provider)
+	}
+	if o.Err != nil {
+		rep.Status = "error"
+		rep.Error = errorInfoOf(o.Err)
+		return rep
+	}
+	rep.LatencyMS = o.Result.Latency.Milliseconds()
+	rep.Code = o.Result.Code
+	rep.Bytes = o.Result.Bytes
+	return rep
+}
+
+func writeProbeReports(w io.Writer, format outputFormat, outcomes []probeOutcome) error {
+	doc := batchDocument[probeReport]{Results: make([]probeReport, 0, len(outcomes))}
+	for _, o := range outcomes {
+		rep := outcomeReport(o)
+		if o.Err == nil {
+			doc.Summary.OK++
+		}
+		if format == formatNDJSON {
+			if err := writeJSON(w, rep); err != nil {
+				return err
+			}
+			continue
+		}
+		doc.Results = append(doc.Results, rep)
+	}
+	if format == formatNDJSON {
+		return nil
+	}
+	doc.Summary.Total = len(outcomes)
+	doc.Summary.Failed = len(outcomes) - doc.Summary.OK
+	return writeJSON(w, doc)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"proxy-node/internal/provider"
+)
+
+func TestSortProbeOutcomes_LatencyThenFailures(t *testing.T) {
+	t.Parallel()
+
+	outcomes := []probeOutcome{
+		{Target: batchTarget{Source: "a"}, Err: errors.New("boom")},
+		{Target: batchTarget{Source: "b"}, Result: probeResult{Latency: 300 * time.Millisecond}},
+		{Target: batchTarget{Source: "c"}, Err: errors.New("boom")},
+		{Target: batchTarget{Source: "d"}, Result: probeResult{Latency: 100 * time.Millisecond}},
+	}
+	sortProbeOutcomes(outcomes)
+
+	var got []string
+	for _, o := range outcomes {
+		got = append(got, o.Target.Source)
+	}
+	if strings.Join(got, ",") != "d,b,a,c" {
+		t.Fatalf("order = %v, want [d b a c]", got)
+	}
+}
+
+func TestCollectTargets_StdinOnlyOnce(t *testing.T) {
+	t.Parallel()
+
+	stdin := strings.NewReader("trojan://secret@example.com:443\n")
+	if _, err := collectTargets(nil, []string{"-", "-"}, 5*time.Second, stdin); err == nil {
+		t.Fatal("collectTargets() error = nil, want an error for a repeated --sub -")
+	}
+}
+
+func TestCollectTargets_URIsAndSubscription(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("trojan://secret@example.com:443\nbroken://x\n"))
+	}))
+	defer srv.Close()
+
+	targets, err := collectTargets([]string{"hy2://secret@example.com"}, []string{srv.URL}, 5*time.Second, nil)
+	if err != nil {
+		t.Fatalf("collectTargets() error = %v", err)
+	}
+	if len(targets) != 3 {
+		t.Fatalf("len(targets) = %d, want 3", len(targets))
+	}
+	if targets[0].Source != "uri:1" || targets[0].Err != nil {
+		t.Fatalf("targets[0] = %+v, want parsed uri:1", targets[0])
+	}
+	if targets[1].Source != srv.URL+":1" || targets[1].Err != nil {
+		t.Fatalf("targets[1] = %+v, want parsed subscription line 1", targets[1])
+	}
+	if targets[2].Err == nil {
+		t.Fatalf("targets[2].Err = nil, want parse error")
+	}
+}
+
+func TestProbeAll_ReportsEveryTarget(t *testing.T) {
+	t.Parallel()
+
+	prov, err := provider.FromURI("trojan://secret@example.com:443")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	targets := []batchTarget{
+		{Source: "uri:1", Err: errors.New("unsupported scheme")},
+		{Source: "uri:2", Provider: prov},
+		{Source: "uri:3", Provider: prov},
+	}
+
+	outcomes := probeAll("/bin/true", targets, 2, "http://127.0.0.1:1/", 300*time.Millisecond, false)
+	if len(outcomes) != len(targets) {
+		t.Fatalf("len(outcomes) = %d, want %d", len(outcomes), len(targets))
+	}
+	for i, o := range outcomes {
+		if o.Target.Source != targets[i].Source {
+			t.Fatalf("outcomes[%d].Source = %q, want %q", i, o.Target.Source, targets[i].Source)
+		}
+		if o.Err == nil {
+			t.Fatalf("outcomes[%d].Err = nil, want failure with stub core", i)
+		}
+	}
+
+	var buf bytes.Buffer
+	printProbeTable(&buf, outcomes)
+	if !strings.Contains(buf.String(), "status=done total=3 ok=0 failed=3") {
+		t.Fatalf("table output = %q, want summary line", buf.String())
+	}
+}
+
+func TestProbeAllShared_CoreFailureFailsBatch(t *testing.T) {
+	t.Parallel()
+
+	prov, err := provider.FromURI("trojan://secret@example.com:443")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	targets := []batchTarget{
+		{Source: "uri:1", Provider: prov},
+		{Source: "uri:2", Err: errors.New("unsupported scheme")},
+		{Source: "uri:3", Provider: prov},
+	}
+
+	outcomes := probeAllShared("/bin/true", targets, 4, "http://127.0.0.1:1/", 300*time.Millisecond, false)
+	if len(outcomes) != len(targets) {
+		t.Fatalf("len(outcomes) = %d, want %d", len(outcomes), len(targets))
+	}
+	if !strings.Contains(outcomes[1].Err.Error(), "unsupported scheme") {
+		t.Fatalf("outcomes[1].Err = %v, want parse error kept", outcomes[1].Err)
+	}
+	for _, i := range []int{0, 2} {
+		if outcomes[i].Err == nil || !strings.Contains(outcomes[i].Err.Error(), "did not become ready") {
+			t.Fatalf("outcomes[%d].Err = %v, want readiness failure", i, outcomes[i].Err)
+		}
+	}
+}
+
+func TestPrintProbeTable_ShowsRemark(t *testing.T) {
+	t.Parallel()
+
+	prov, err := provider.FromURI("trojan://secret@example.com:443#%F0%9F%87%A9%F0%9F%87%AA%20Frankfurt%2001")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	var buf bytes.Buffer
+	printProbeTable(&buf, []probeOutcome{{Target: batchTarget{Source: "uri:1", Provider: prov}, Err: errors.New("timeout")}})
+	if !strings.Contains(buf.String(), "🇩🇪 Frankfurt 01") {
+		t.Fatalf("table = %q, want remark", buf.String())
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"proxy-node/internal/provider"
+)
+
+const maxSubscriptionBytes = 16 << 20
+
+func runSubscribe(args []string) error {
+	fs := flag.NewFlagSet("subscribe", flag.ContinueOnError)
+	sub := fs.String("sub", "", "subscription URL, file path, or - for stdin")
+	doProbe := fs.Bool("probe", false, "probe every parsed node")
+	corePath := fs.String("core", "", "core binary path")
+	probeURL := fs.String("url", defaultProbeURL, "probe URL")
+	timeout := fs.Duration("timeout", 20*time.Second, "fetch and per-node probe timeout")
+	output := fs.String("output", "text", "output format: text|json|ndjson")
+	keepTemp := fs.Bool("keep-temp", false, "keep the core's temp config and logs after exit (for debugging)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	format, err := parseOutputFormat(*output, true)
+	if err != nil {
+		return err
+	}
+	if *sub == "" {
+		return errors.New("--sub is required")
+	}
+
+	fetchCtx, cancel := context.WithTimeout(context.Background(), *timeout)
+	body, err := loadSubscription(fetchCtx, *sub, os.Stdin)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	entries := provider.ParseSubscription(body)
+	if len(entries) == 0 {
+		return errors.New("subscription contains no share links")
+	}
+
+	if !*doProbe {
+		return writeLinkReports(format, entries)
+	}
+
+	resolvedCore, err := resolveCorePath(*corePath)
+	if err != nil {
+		return err
+	}
+
+	var parsed, ok int
+	var reports []probeReport
+	for _, e := range entries {
+		source := fmt.Sprintf("line:%d", e.Line)
+		if e.Err != nil {
+			if format == formatText {
+				fmt.Printf("status=error line=%d error=%q\n", e.Line, e.Err.Error())
+			}
+			reports = append(reports, outcomeReport(probeOutcome{Target: batchTarget{Source: source}, Err: withStage(stageInput, e.Err)}))
+			continue
+		}
+		parsed++
+
+		target := batchTarget{Source: source, Raw: e.Raw, Provider: e.Provider}
+		res, err := probeTarget(resolvedCore, e.Provider, 0, *probeURL, *timeout, *keepTemp)
+		reports = append(reports, outcomeReport(probeOutcome{Target: target, Result: res, Err: err}))
+		if err != nil {
+			if format == formatText {
+				fmt.Printf("status=fail line=%d protocol=%s error=%q%s\n", e.Line, e.Provider.Name(), firstLine(err.Error()), remarkField(e.Provider))
+			}
+			continue
+		}
+		ok++
+		if format == formatText {
+			fmt.Printf("status=ok line=%d protocol=%s code=%d latency_ms=%d bytes=%d%s\n",
+				e.Line, e.Provider.Name(), res.Code, res.Latency.Milliseconds(), res.Bytes, remarkField(e.Provider))
+		}
+	}
+	for i := range reports {
+		reports[i].Command = "subscribe"
+	}
+
+	switch format {
+	case formatText:
+		fmt.Printf("status=done total=%d parsed=%d ok=%d failed=%d\n", len(entries), parsed, ok, len(entries)-ok)
+		return nil
+	case formatNDJSON:
+		for _, rep := range reports {
+			if err := writeJSON(os.Stdout, rep); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return writeJSON(os.Stdout, batchDocument[probeReport]{
+			Results: reports,
+			Summary: batchSummary{Total: len(entries), OK: ok, Failed: len(entries) - ok},
+		})
+	}
+}
+
+func writeLinkReports(format outputFormat, entries []provider.SubscriptionEntry) error {
+	reports := make([]linkReport, 0, len(entries))
+	var failed int
+	for _, e := range entries {
+		rep := linkReport{Status: "parsed", Command: "subscribe", Line: e.Line}
+		if e.Err != nil {
+			failed++
+			rep.Status = "error"
+			rep.Error = errorInfoOf(withStage(stageInput, e.Err))
+		} else {
+			rep.Protocol = e.Provider.Name()
+			rep.Remark = provider.LabelOf(e.Provider)
+			rep.Address = provider.EndpointOf(e.Provider)
+		}
+		reports = append(reports, rep)
+	}
+
+	switch format {
+	case formatText:
+		for i, rep := range reports {
+			if rep.Error != nil {
+				fmt.Printf("status=error line=%d error=%q\n", rep.Line, rep.Error.Message)
+				continue
+			}
+			fmt.Printf("status=parsed line=%d protocol=%s address=%s%s\n", rep.Line, rep.Protocol, rep.Address, remarkField(entries[i].Provider))
+		}
+		fmt.Printf("status=done total=%d parsed=%d failed=%d\n", len(entries), len(entries)-failed, failed)
+		return nil
+	case formatNDJSON:
+		for _, rep := range reports {
+			if err := writeJSON(os.Stdout, rep); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return writeJSON(os.Stdout, batchDocument[linkReport]{
+			Results: reports,
+			Summary: batchSummary{Total: len(entries), OK: len(entries) - failed, Failed: failed},
+		})
+	}
+}
+
+// loadSubscription reads a feed from an http(s) URL, a local file, or stdin
+// when source is "-".
+func loadSubscription(ctx context.Context, source string, stdin io.Reader) ([]byte, error) {
+	lower := strings.ToLower(source)
+	switch {
+	case source == "-":
+		body, err := readSubscription(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("read subscription from stdin: %w", err)
+		}
+		return body, nil
+	case strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://"):
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, fmt.Errorf("build subscription request: %w", err)
+		}
+		req.Header.Set("User-Agent", "proxy-node")
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("fetch subscription: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+			return nil, fmt.Errorf("subscription returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		body, err := readSubscription(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("read subscription: %w", err)
+		}
+		return body, nil
+	default:
+		f, err := os.Open(source)
+		if err != nil {
+			return nil, fmt.Errorf("read subscription file: %w", err)
+		}
+		defer f.Close()
+		body, err := readSubscription(f)
+		if err != nil {
+			return nil, fmt.Errorf("read subscription file: %w", err)
+		}
+		return body, nil
+	}
+}
+
+// readSubscription reads at most maxSubscriptionBytes, failing instead of
+// truncating a longer feed mid-link.
+func readSubscription(r io.Reader) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, maxSubscriptionBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxSubscriptionBytes {
+		return nil, fmt.Errorf("subscription exceeds %d MiB", maxSubscriptionBytes>>20)
+	}
+	return body, nil
+}
+
+func firstLine(v string) string {
+	line, _, _ := strings.Cut(v, "\n")
+	return line
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"proxy-node/internal/provider"
+)
+
+func TestLoadSubscription_HTTP(t *testing.T) {
+	t.Parallel()
+
+	links := "trojan://secret@example.com:443\nnot-a-link\nvless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(base64.StdEncoding.EncodeToString([]byte(links))))
+	}))
+	defer srv.Close()
+
+	body, err := loadSubscription(context.Background(), srv.URL, nil)
+	if err != nil {
+		t.Fatalf("loadSubscription() error = %v", err)
+	}
+	entries := provider.ParseSubscription(body)
+	if len(entries) != 3 {
+		t.Fatalf("len(entries) = %d, want 3", len(entries))
+	}
+	if entries[0].Err != nil || entries[2].Err != nil {
+		t.Fatalf("entries = %+v, want lines 1 and 3 parsed", entries)
+	}
+	if entries[1].Err == nil {
+		t.Fatal("entries[1].Err = nil, want parse error")
+	}
+}
+
+func TestLoadSubscription_HTTPErrorStatus(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	}))
+	defer srv.Close()
+
+	_, err := loadSubscription(context.Background(), srv.URL, nil)
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("loadSubscription() error = %v, want 404 error", err)
+	}
+}
+
+func TestLoadSubscription_FileAndStdin(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "feed.txt")
+	if err := os.WriteFile(path, []byte("trojan://secret@example.com:443\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	body, err := loadSubscription(context.Background(), path, nil)
+	if err != nil {
+		t.Fatalf("loadSubscription(file) error = %v", err)
+	}
+	if !strings.HasPrefix(string(body), "trojan://") {
+		t.Fatalf("loadSubscription(file) = %q", body)
+	}
+
+	body, err = loadSubscription(context.Background(), "-", strings.NewReader("hy2://secret@example.com"))
+	if err != nil {
+		t.Fatalf("loadSubscription(stdin) error = %v", err)
+	}
+	if string(body) != "hy2://secret@example.com" {
+		t.Fatalf("loadSubscription(stdin) = %q", body)
+	}
+}
+
+func TestLoadSubscription_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	feed := strings.Repeat("a", maxSubscriptionBytes+1)
+	_, err := loadSubscription(context.Background(), "-", strings.NewReader(feed))
+	if err == nil || !strings.Contains(err.Error(), "exceeds 16 MiB") {
+		t.Fatalf("loadSubscription() error = %v, want a size error", err)
+	}
+	body, err := loadSubscription(context.Background(), "-", strings.NewReader(feed[1:]))
+	if err != nil || len(body) != maxSubscriptionBytes {
+		t.Fatalf("loadSubscription(at limit) = %d bytes, %v", len(body), err)
+	}
+}
@@ -3,11 +3,15 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 )
 
@@ -17,22 +21,279 @@ type Runner struct {
 	Timeout         time.Duration
 	InboundProtocol string
 	LogLevel        string
+	// Listen is the inbound listen address, 127.0.0.1 when empty. IPv6
+	// addresses may be bracketed.
+	Listen string
+	// User and Password, when User is set, require authentication on the
+	// inbound.
+	User     string
+	Password string
+	// SkipValidate disables the core's own config test run before start.
+	SkipValidate bool
+	// Core describes CorePath. When nil, Start detects it (cached per binary).
+	Core *Info
+	// StopGrace is how long Stop waits after SIGTERM before killing the
+	// core. Zero means DefaultStopGrace.
+	StopGrace time.Duration
+	// KeepTempDir leaves the config and logs on disk after Stop, for
+	// debugging. The config contains the node's credentials.
+	KeepTempDir bool
 }
 
+// DefaultStopGrace gives the core time to close its connections.
+const DefaultStopGrace = 3 * time.Second
+
+// ConfigError reports a config the core rejected in its test mode.
+type ConfigError struct {
+	CorePath   string
+	Diagnostic string
+	Err        error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Diagnostic == "" {
+		return fmt.Sprintf("core rejected config: %v", e.Err)
+	}
+	return fmt.Sprintf("core rejected config: %s", e.Diagnostic)
+}
+
+func (e *ConfigError) Unwrap() error { return e.Err }
+
 type Started struct {
 	Cmd           *exec.Cmd
 	ConfigPath    string
 	LogPath       string
 	AccessLogPath string
+	// Routes lists the local inbound port serving each outbound, in the order
+	// the outbounds were passed to Start/StartMany.
+	Routes []Route
+	// Core is the detected core, or nil when detection failed.
+	Core *Info
+	// Downgraded lists optional features dropped from the outbounds because
+	// the core does not support them.
+	Downgraded []Capability
+	// Dir holds the config and logs. Stop removes it unless the Runner set
+	// KeepTempDir.
+	Dir string
+
+	keepDir  bool
+	quietLog bool   // log level hides the started line
+	dialHost string // address the inbounds are reachable on
+	grace    time.Duration
+	exited   chan struct{} // closed once Cmd.Wait returns
+	waitErr  error
+	stopped  chan struct{} // closed once Stop has finished
+	stopOnce sync.Once
+
+	mu    sync.Mutex
+	tails map[string]string // log tails saved before Dir is removed
 }
 
+// Route ties one local inbound to the outbound it is routed to.
+type Route struct {
+	InboundTag  string
+	OutboundTag string
+	Port        int
+}
+
+// Start runs the core with a single outbound. Outbounds needing features the
+// detected core lacks fail with *UnsupportedError before anything is written.
 func (r Runner) Start(ctx context.Context, outbound map[string]any) (*Started, error) {
+	info := r.detect(ctx)
+	outbounds, dropped, err := adaptAll(info, []map[string]any{outbound})
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := r.BuildConfig(outbounds[0])
+	if err != nil {
+		return nil, err
+	}
+	return r.launch(ctx, cfg, info, dropped)
+}
+
+// StartMany runs one core process serving every outbound on its own local
+// inbound: ports[i] reaches outbounds[i] through a routing rule keyed on the
+// inbound tag. Outbound maps are copied and retagged, never modified.
+func (r Runner) StartMany(ctx context.Context, outbounds []map[string]any, ports []int) (*Started, error) {
+	info := r.detect(ctx)
+	outbounds, dropped, err := adaptAll(info, outbounds)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := r.BuildManyConfig(outbounds, ports)
+	if err != nil {
+		return nil, err
+	}
+	return r.launch(ctx, cfg, info, dropped)
+}
+
+// detect returns r.Core, or detects CorePath. Detection failures yield nil:
+// the core then runs unchecked, as before detection existed.
+func (r Runner) detect(ctx context.Context) *Info {
+	if r.Core != nil {
+		return r.Core
+	}
 	if r.CorePath == "" {
-		return nil, fmt.Errorf("core path is required")
+		return nil
+	}
+	info, err := Detect(ctx, r.CorePath)
+	if err != nil {
+		return nil
 	}
+	return info
+}
+
+func adaptAll(info *Info, outbounds []map[string]any) ([]map[string]any, []Capability, error) {
+	adapted := make([]map[string]any, len(outbounds))
+	var dropped []Capability
+	for i, ob := range outbounds {
+		out, d, err := info.Adapt(ob)
+		if err != nil {
+			return nil, nil, err
+		}
+		adapted[i] = out
+		for _, c := range d {
+			if !containsCapability(dropped, c) {
+				dropped = append(dropped, c)
+			}
+		}
+	}
+	return adapted, dropped, nil
+}
+
+func containsCapability(list []Capability, c Capability) bool {
+	for _, v := range list {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Config is a core configuration as Start and StartMany write it. Log file
+// paths are empty until the runner assigns its temp dir; an exported config
+// without them logs to stdout/stderr.
+type Config struct {
+	LogLevel      string
+	ErrorLogPath  string
+	AccessLogPath string
+	Inbounds      []any
+	Outbounds     []any
+	Rules         []any
+	Routes        []Route
+}
+
+// BuildConfig returns the config Start runs for a single outbound on r.Port.
+func (r Runner) BuildConfig(outbound map[string]any) (*Config, error) {
 	if r.Port == 0 {
 		return nil, fmt.Errorf("local socks port is required")
 	}
+	inbound, err := r.inbound(r.Port)
+	if err != nil {
+		return nil, err
+	}
+	tag, _ := outbound["tag"].(string)
+	return &Config{
+		LogLevel:  r.logLevel(),
+		Inbounds:  []any{inbound},
+		Outbounds: []any{outbound},
+		Routes:    []Route{{OutboundTag: tag, Port: r.Port}},
+	}, nil
+}
+
+// BuildManyConfig returns the config StartMany runs.
+func (r Runner) BuildManyConfig(outbounds []map[string]any, ports []int) (*Config, error) {
+	if len(outbounds) == 0 {
+		return nil, fmt.Errorf("at least one outbound is required")
+	}
+	if len(ports) != len(outbounds) {
+		return nil, fmt.Errorf("got %d ports for %d outbounds", len(ports), len(outbounds))
+	}
+
+	cfg := &Config{LogLevel: r.logLevel()}
+	seen := make(map[int]bool, len(ports))
+	for i, ob := range outbounds {
+		port := ports[i]
+		if port <= 0 || port > 65535 {
+			return nil, fmt.Errorf("invalid local port %d for outbound %d", port, i)
+		}
+		if seen[port] {
+			return nil, fmt.Errorf("local port %d used more than once", port)
+		}
+		seen[port] = true
+
+		route := Route{
+			InboundTag:  fmt.Sprintf("in-%d", i),
+			OutboundTag: fmt.Sprintf("proxy-%d", i),
+			Port:        port,
+		}
+		inbound, err := r.inbound(port)
+		if err != nil {
+			return nil, err
+		}
+		inbound["tag"] = route.InboundTag
+		cfg.Inbounds = append(cfg.Inbounds, inbound)
+
+		tagged := make(map[string]any, len(ob))
+		for k, v := range ob {
+			tagged[k] = v
+		}
+		tagged["tag"] = route.OutboundTag
+		cfg.Outbounds = append(cfg.Outbounds, tagged)
+
+		cfg.Rules = append(cfg.Rules, map[string]any{
+			"type":        "field",
+			"inboundTag":  []string{route.InboundTag},
+			"outboundTag": route.OutboundTag,
+		})
+		cfg.Routes = append(cfg.Routes, route)
+	}
+	return cfg, nil
+}
+
+// Map returns the JSON document the core reads.
+func (c *Config) Map() map[string]any {
+	logCfg := map[string]any{"loglevel": c.LogLevel}
+	if c.AccessLogPath != "" {
+		logCfg["access"] = c.AccessLogPath
+	}
+	if c.ErrorLogPath != "" {
+		logCfg["error"] = c.ErrorLogPath
+	}
+
+	outbounds := make([]any, 0, len(c.Outbounds)+1)
+	outbounds = append(outbounds, c.Outbounds...)
+	outbounds = append(outbounds, map[string]any{"tag": "direct", "protocol": "freedom"})
+
+	m := map[string]any{
+		"log":       logCfg,
+		"inbounds":  c.Inbounds,
+		"outbounds": outbounds,
+	}
+	if len(c.Rules) > 0 {
+		m["routing"] = map[string]any{"rules": c.Rules}
+	}
+	return m
+}
+
+// JSON returns the indented config file body.
+func (c *Config) JSON() ([]byte, error) {
+	body, err := json.MarshalIndent(c.Map(), "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshal core config: %w", err)
+	}
+	return body, nil
+}
+
+func (r Runner) logLevel() string {
+	logLevel := strings.TrimSpace(r.LogLevel)
+	if logLevel == "" {
+		logLevel = "warning"
+	}
+	return logLevel
+}
+
+func (r Runner) inbound(port int) (map[string]any, error) {
 	inboundProtocol := strings.TrimSpace(r.InboundProtocol)
 	if inboundProtocol == "" {
 		inboundProtocol = "socks"
@@ -40,59 +301,89 @@ func (r Runner) Start(ctx context.Context, outbound map[string]any) (*Started, e
 	if inboundProtocol != "socks" && inboundProtocol != "http" {
 		return nil, fmt.Errorf("unsupported inbound protocol %q", inboundProtocol)
 	}
-	logLevel := strings.TrimSpace(r.LogLevel)
-	if logLevel == "" {
-		logLevel = "warning"
-	}
 
 	inbound := map[string]any{
-		"listen":   "127.0.0.1",
-		"port":     r.Port,
+		"listen":   r.listen(),
+		"port":     port,
 		"protocol": inboundProtocol,
 	}
+	settings := map[string]any{}
 	if inboundProtocol == "socks" {
-		inbound["settings"] = map[string]any{"udp": true}
+		settings["udp"] = true
+	}
+	if r.User != "" {
+		if inboundProtocol == "socks" {
+			settings["auth"] = "password"
+		}
+		settings["accounts"] = []any{map[string]any{"user": r.User, "pass": r.Password}}
+	}
+	if len(settings) > 0 {
+		inbound["settings"] = settings
+	}
+	return inbound, nil
+}
+
+func (r Runner) listen() string {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(r.Listen), "["), "]")
+	if host == "" {
+		return "127.0.0.1"
+	}
+	return host
+}
+
+// dialHost is where the inbounds can be reached from this machine.
+func (r Runner) dialHost() string {
+	host := r.listen()
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		if ip.To4() != nil {
+			return "127.0.0.1"
+		}
+		return "::1"
 	}
+	return host
+}
 
-	cfg := map[string]any{
-		"log": map[string]any{
-			"loglevel": logLevel,
-		},
-		"inbounds": []any{inbound},
-		"outbounds": []any{
-			outbound,
-			map[string]any{"tag": "direct", "protocol": "freedom"},
-		},
+func (r Runner) launch(ctx context.Context, cfg *Config, info *Info, dropped []Capability) (*Started, error) {
+	if r.CorePath == "" {
+		return nil, fmt.Errorf("core path is required")
 	}
 
 	dir, err := os.MkdirTemp("", "proxy-node-")
 	if err != nil {
 		return nil, fmt.Errorf("create temp dir: %w", err)
 	}
+	started := false
+	defer func() {
+		if !started && !r.KeepTempDir {
+			_ = os.RemoveAll(dir)
+		}
+	}()
 	configPath := filepath.Join(dir, "config.json")
 	logPath := filepath.Join(dir, "core.log")
 	accessLogPath := filepath.Join(dir, "access.log")
 
-	cfg["log"] = map[string]any{
-		"loglevel": logLevel,
-		"access":   accessLogPath,
-		"error":    logPath,
-	}
-	body, err := json.MarshalIndent(cfg, "", "  ")
+	cfg.ErrorLogPath = logPath
+	cfg.AccessLogPath = accessLogPath
+	body, err := cfg.JSON()
 	if err != nil {
-		return nil, fmt.Errorf("marshal core config: %w", err)
+		return nil, err
 	}
 	if err := os.WriteFile(configPath, body, 0o600); err != nil {
 		return nil, fmt.Errorf("write config: %w", err)
 	}
 
+	if !r.SkipValidate {
+		if err := r.validateFile(ctx, info, configPath); err != nil {
+			return nil, err
+		}
+	}
+
 	logf, err := os.Create(logPath)
 	if err != nil {
 		return nil, fmt.Errorf("create log file: %w", err)
 	}
-
-	args := coreArgs(r.CorePath, configPath)
-	cmd := exec.CommandContext(ctx, r.CorePath, args...)
+	args := commandArgs(info, r.CorePath, configPath, false)
+	cmd := exec.Command(r.CorePath, args...)
 	cmd.Stdout = logf
 	cmd.Stderr = logf
 
@@ -101,16 +392,172 @@ func (r Runner) Start(ctx context.Context, outbound map[string]any) (*Started, e
 		return nil, fmt.Errorf("start core: %w", err)
 	}
 	_ = logf.Close()
+	started = true
+
+	grace := r.StopGrace
+	if grace <= 0 {
+		grace = DefaultStopGrace
+	}
+	s := &Started{
+		Cmd:           cmd,
+		ConfigPath:    configPath,
+		LogPath:       logPath,
+		AccessLogPath: accessLogPath,
+		Routes:        cfg.Routes,
+		Core:          info,
+		Downgraded:    dropped,
+		Dir:           dir,
+		keepDir:       r.KeepTempDir,
+		quietLog:      hidesStartedLine(cfg.LogLevel),
+		dialHost:      r.dialHost(),
+		grace:         grace,
+		exited:        make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	go func() {
+		s.waitErr = cmd.Wait()
+		close(s.exited)
+	}()
+	// Stop the core and remove its files when ctx ends, even if the caller
+	// never gets to call Stop.
+	go func() {
+		select {
+		case <-ctx.Done():
+			s.Stop()
+		case <-s.stopped:
+		}
+	}()
+	return s, nil
+}
+
+// Validate runs the core in test mode against cfg without starting it.
+func (r Runner) Validate(ctx context.Context, cfg *Config) error {
+	if r.CorePath == "" {
+		return fmt.Errorf("core path is required")
+	}
+	dir, err := os.MkdirTemp("", "proxy-node-validate-")
+	if err != nil {
+		return fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
 
-	return &Started{Cmd: cmd, ConfigPath: configPath, LogPath: logPath, AccessLogPath: accessLogPath}, nil
+	body, err := cfg.JSON()
+	if err != nil {
+		return err
+	}
+	configPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configPath, body, 0o600); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return r.validateFile(ctx, r.detect(ctx), configPath)
 }
 
+func (r Runner) validateFile(ctx context.Context, info *Info, configPath string) error {
+	out, err := exec.CommandContext(ctx, r.CorePath, commandArgs(info, r.CorePath, configPath, true)...).CombinedOutput()
+	if err == nil {
+		return nil
+	}
+	if ctx.Err() != nil {
+		return fmt.Errorf("validate config: %w", ctx.Err())
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return fmt.Errorf("run core config test: %w", err)
+	}
+	diag := strings.TrimSpace(string(out))
+	if len(diag) > 4000 {
+		diag = diag[len(diag)-4000:]
+	}
+	return &ConfigError{CorePath: r.CorePath, Diagnostic: diag, Err: err}
+}
+
+// Stop asks the core to exit with SIGTERM, kills it if it is still running
+// after the grace period and removes Dir unless it is kept. Log tails stay
+// readable afterwards. Stop may be called more than once.
 func (s *Started) Stop() {
-	if s == nil || s.Cmd == nil || s.Cmd.Process == nil {
+	if s == nil {
 		return
 	}
-	_ = s.Cmd.Process.Kill()
-	_, _ = s.Cmd.Process.Wait()
+	s.stopOnce.Do(func() {
+		s.terminate()
+		s.removeDir()
+		if s.stopped != nil {
+			close(s.stopped)
+		}
+	})
+}
+
+// Done is closed when the core process exits, for whatever reason.
+func (s *Started) Done() <-chan struct{} {
+	return s.exited
+}
+
+// ExitCode is the core's exit status once Done is closed: -1 if it was
+// killed by a signal or has not exited yet.
+func (s *Started) ExitCode() int {
+	select {
+	case <-s.exited:
+	default:
+		return -1
+	}
+	if s.Cmd.ProcessState == nil {
+		return -1
+	}
+	return s.Cmd.ProcessState.ExitCode()
+}
+
+// Err is the error Cmd.Wait returned, once Done is closed.
+func (s *Started) Err() error {
+	select {
+	case <-s.exited:
+		return s.waitErr
+	default:
+		return nil
+	}
+}
+
+func (s *Started) terminate() {
+	if s.Cmd == nil || s.Cmd.Process == nil {
+		return
+	}
+	if s.exited == nil {
+		_ = s.Cmd.Process.Kill()
+		_, _ = s.Cmd.Process.Wait()
+		return
+	}
+	select {
+	case <-s.exited:
+		return
+	default:
+	}
+	if err := s.Cmd.Process.Signal(syscall.SIGTERM); err != nil {
+		// No SIGTERM on Windows.
+		_ = s.Cmd.Process.Kill()
+	}
+	timer := time.NewTimer(s.grace)
+	defer timer.Stop()
+	select {
+	case <-s.exited:
+	case <-timer.C:
+		_ = s.Cmd.Process.Kill()
+		<-s.exited
+	}
+}
+
+func (s *Started) removeDir() {
+	if s.Dir == "" || s.keepDir {
+		return
+	}
+	tails := map[string]string{}
+	for _, path := range []string{s.LogPath, s.AccessLogPath} {
+		if path != "" {
+			tails[path] = readTail(path)
+		}
+	}
+	s.mu.Lock()
+	s.tails = tails
+	s.mu.Unlock()
+	_ = os.RemoveAll(s.Dir)
 }
 
 func (s *Started) ReadLogTail() string {
@@ -125,6 +572,16 @@ func (s *Started) readTailFile(path string) string {
 	if s == nil || path == "" {
 		return ""
 	}
+	s.mu.Lock()
+	tail, saved := s.tails[path]
+	s.mu.Unlock()
+	if saved {
+		return tail
+	}
+	return readTail(path)
+}
+
+func readTail(path string) string {
 	b, err := os.ReadFile(path)
 	if err != nil {
 		return ""
@@ -142,3 +599,13 @@ func coreArgs(corePath, configPath string) []string {
 	}
 	return []string{"-config", configPath}
 }
+
+// coreTestArgs mirrors coreArgs: Xray takes "run -test", V2Ray builds started
+// with a bare -config take a bare -test.
+func coreTestArgs(corePath, configPath string) []string {
+	base := strings.ToLower(filepath.Base(corePath))
+	if strings.Contains(base, "xray") {
+		return []string{"run", "-test", "-c", configPath}
+	}
+	return []string{"-test", "-config", configPath}
+}
@@ -3,8 +3,10 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -122,3 +124,326 @@ func TestStarted_ReadAccessLogTail(t *testing.T) {
 		t.Fatalf("len(ReadAccessLogTail()) = %d, want 4000", len(got))
 	}
 }
+
+func TestRunnerStartMany_RoutesEachInboundToItsOutbound(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	r := Runner{CorePath: "/bin/true", Timeout: 5 * time.Second}
+	outbounds := []map[string]any{
+		{"tag": "proxy", "protocol": "vless"},
+		{"tag": "proxy", "protocol": "trojan"},
+	}
+	started, err := r.StartMany(ctx, outbounds, []int{21001, 21002})
+	if err != nil {
+		t.Fatalf("StartMany() error = %v", err)
+	}
+	defer started.Stop()
+
+	if outbounds[0]["tag"] != "proxy" {
+		t.Fatalf("input outbound was modified: %#v", outbounds[0])
+	}
+	if len(started.Routes) != 2 || started.Routes[1].Port != 21002 {
+		t.Fatalf("Routes = %+v, want two routes with second on 21002", started.Routes)
+	}
+
+	raw, err := os.ReadFile(started.ConfigPath)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) error = %v", started.ConfigPath, err)
+	}
+	var cfg map[string]any
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		t.Fatalf("Unmarshal(config) error = %v", err)
+	}
+
+	inbounds, _ := cfg["inbounds"].([]any)
+	outs, _ := cfg["outbounds"].([]any)
+	if len(inbounds) != 2 || len(outs) != 3 {
+		t.Fatalf("got %d inbounds and %d outbounds, want 2 and 3", len(inbounds), len(outs))
+	}
+	second, _ := outs[1].(map[string]any)
+	if second["tag"] != started.Routes[1].OutboundTag || second["protocol"] != "trojan" {
+		t.Fatalf("outbounds[1] = %#v, want trojan tagged %q", second, started.Routes[1].OutboundTag)
+	}
+
+	routing, _ := cfg["routing"].(map[string]any)
+	rules, _ := routing["rules"].([]any)
+	if len(rules) != 2 {
+		t.Fatalf("routing.rules = %#v, want 2 rules", routing["rules"])
+	}
+	rule, _ := rules[1].(map[string]any)
+	tags, _ := rule["inboundTag"].([]any)
+	if len(tags) != 1 || tags[0] != started.Routes[1].InboundTag || rule["outboundTag"] != started.Routes[1].OutboundTag {
+		t.Fatalf("routing.rules[1] = %#v, want %+v", rule, started.Routes[1])
+	}
+}
+
+func TestRunnerStartMany_RejectsDuplicatePorts(t *testing.T) {
+	t.Parallel()
+
+	r := Runner{CorePath: "/bin/true"}
+	_, err := r.StartMany(context.Background(), []map[string]any{{}, {}}, []int{21001, 21001})
+	if err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Fatalf("StartMany() error = %v, want duplicate port error", err)
+	}
+}
+
+func TestRunnerBuildConfig_ListenAndAuth(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		r        Runner
+		listen   string
+		settings map[string]any
+	}{
+		{Runner{Port: 1080}, "127.0.0.1", map[string]any{"udp": true}},
+		{Runner{Port: 1080, Listen: "0.0.0.0"}, "0.0.0.0", map[string]any{"udp": true}},
+		{Runner{Port: 1080, Listen: "[::]"}, "::", map[string]any{"udp": true}},
+		{Runner{Port: 8080, InboundProtocol: "http"}, "127.0.0.1", nil},
+		{
+			Runner{Port: 1080, Listen: "192.168.1.10", User: "me", Password: "s3cret"},
+			"192.168.1.10",
+			map[string]any{"udp": true, "auth": "password", "accounts": []any{map[string]any{"user": "me", "pass": "s3cret"}}},
+		},
+		{
+			Runner{Port: 8080, InboundProtocol: "http", User: "me", Password: "s3cret"},
+			"127.0.0.1",
+			map[string]any{"accounts": []any{map[string]any{"user": "me", "pass": "s3cret"}}},
+		},
+	}
+	for _, c := range cases {
+		cfg, err := c.r.BuildConfig(map[string]any{"tag": "proxy", "protocol": "freedom"})
+		if err != nil {
+			t.Fatalf("BuildConfig(%+v) error = %v", c.r, err)
+		}
+		inbound := cfg.Inbounds[0].(map[string]any)
+		settings, _ := inbound["settings"].(map[string]any)
+		if inbound["listen"] != c.listen || !reflect.DeepEqual(settings, c.settings) {
+			t.Fatalf("BuildConfig(%+v) inbound = %#v", c.r, inbound)
+		}
+	}
+}
+
+func TestRunnerBuildConfig_MatchesStartedConfig(t *testing.T) {
+	t.Parallel()
+
+	r := Runner{CorePath: "/bin/true", Port: 1080, InboundProtocol: "http", LogLevel: "info"}
+	outbound := map[string]any{"tag": "proxy", "protocol": "freedom"}
+
+	cfg, err := r.BuildConfig(outbound)
+	if err != nil {
+		t.Fatalf("BuildConfig() error = %v", err)
+	}
+	exported := cfg.Map()
+	logCfg, _ := exported["log"].(map[string]any)
+	if _, ok := logCfg["error"]; ok {
+		t.Fatalf("exported log = %#v, want no file paths", logCfg)
+	}
+
+	started, err := r.Start(context.Background(), outbound)
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer started.Stop()
+	raw, err := os.ReadFile(started.ConfigPath)
+	if err != nil {
+		t.Fatalf("ReadFile(%q) error = %v", started.ConfigPath, err)
+	}
+	var written map[string]any
+	if err := json.Unmarshal(raw, &written); err != nil {
+		t.Fatalf("Unmarshal(config) error = %v", err)
+	}
+
+	body, err := cfg.JSON()
+	if err != nil {
+		t.Fatalf("JSON() error = %v", err)
+	}
+	var built map[string]any
+	if err := json.Unmarshal(body, &built); err != nil {
+		t.Fatalf("Unmarshal(built) error = %v", err)
+	}
+	delete(written, "log")
+	delete(built, "log")
+	if !reflect.DeepEqual(written, built) {
+		t.Fatalf("built config = %#v, want %#v", built, written)
+	}
+}
+
+func TestRunnerStart_ConfigErrorFromCoreTest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	corePath := filepath.Join(dir, "xray")
+	script := "#!/bin/sh\nif [ \"$1\" = \"version\" ]; then echo 'Xray 25.1.1 (Xray, Penetrates Everything.)'; exit 0; fi\nif [ \"$2\" = \"-test\" ]; then echo 'Failed to build: invalid user id' >&2; exit 23; fi\nsleep 5\n"
+	if err := os.WriteFile(corePath, []byte(script), 0o755); err != nil {
+		t.Fatalf("WriteFile(stub core) error = %v", err)
+	}
+
+	r := Runner{CorePath: corePath, Port: 1080}
+	_, err := r.Start(context.Background(), map[string]any{"tag": "proxy", "protocol": "vless"})
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Start() error = %v, want *ConfigError", err)
+	}
+	if !strings.Contains(cfgErr.Diagnostic, "invalid user id") {
+		t.Fatalf("Diagnostic = %q, want core output", cfgErr.Diagnostic)
+	}
+
+	cfg, err := r.BuildConfig(map[string]any{"tag": "proxy", "protocol": "vless"})
+	if err != nil {
+		t.Fatalf("BuildConfig() error = %v", err)
+	}
+	if err := r.Validate(context.Background(), cfg); !errors.As(err, &cfgErr) {
+		t.Fatalf("Validate() error = %v, want *ConfigError", err)
+	}
+}
+
+func TestCoreTestArgs(t *testing.T) {
+	t.Parallel()
+
+	if got := coreTestArgs("/opt/xray", "c.json"); !reflect.DeepEqual(got, []string{"run", "-test", "-c", "c.json"}) {
+		t.Fatalf("coreTestArgs(xray) = %v", got)
+	}
+	if got := coreTestArgs("/opt/v2ray", "c.json"); !reflect.DeepEqual(got, []string{"-test", "-config", "c.json"}) {
+		t.Fatalf("coreTestArgs(v2ray) = %v", got)
+	}
+}
+
+// stubCore writes an Xray stand-in that passes detection and the config
+// test, prints "started" and then runs onRun (a shell snippet) forever.
+func stubCore(t *testing.T, onRun string) string {
+	t.Helper()
+	return scriptCore(t, "echo started\n"+onRun+"\nwhile :; do sleep 0.05; done")
+}
+
+// scriptCore is stubCore without the start-up: once past detection and the
+// config test the stand-in runs script and nothing else. The config path is
+// "$3".
+func scriptCore(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "xray")
+	body := "#!/bin/sh\n" +
+		"if [ \"$1\" = \"version\" ]; then echo 'Xray 25.1.1 (Xray, Penetrates Everything.)'; exit 0; fi\n" +
+		"if [ \"$2\" = \"-test\" ]; then exit 0; fi\n" +
+		script + "\n"
+	if err := os.WriteFile(path, []byte(body), 0o755); err != nil {
+		t.Fatalf("WriteFile(stub core) error = %v", err)
+	}
+	return path
+}
+
+func startStub(t *testing.T, ctx context.Context, r Runner) *Started {
+	t.Helper()
+	r.Port = 1080
+	started, err := r.Start(ctx, map[string]any{"tag": "proxy", "protocol": "freedom"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if started.Dir == "" || !strings.HasPrefix(filepath.Base(started.Dir), "proxy-node-") {
+		t.Fatalf("Dir = %q", started.Dir)
+	}
+	// Let the script install its trap before it is signalled.
+	_ = started.WaitReady(ctx, 2*time.Second)
+	return started
+}
+
+func TestStartedStop_TermThenCleanup(t *testing.T) {
+	t.Parallel()
+
+	marker := filepath.Join(t.TempDir(), "terminated")
+	core := stubCore(t, "trap 'echo bye; touch "+marker+"; exit 0' TERM")
+	started := startStub(t, context.Background(), Runner{CorePath: core, StopGrace: 5 * time.Second})
+
+	begin := time.Now()
+	started.Stop()
+	if d := time.Since(begin); d > 2*time.Second {
+		t.Fatalf("Stop() took %v, want the core to exit on SIGTERM", d)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Fatalf("core did not get SIGTERM: %v", err)
+	}
+	if _, err := os.Stat(started.Dir); !os.IsNotExist(err) {
+		t.Fatalf("temp dir still exists after Stop: %v", err)
+	}
+	if tail := started.ReadLogTail(); !strings.Contains(tail, "started") || !strings.Contains(tail, "bye") {
+		t.Fatalf("ReadLogTail() after Stop = %q", tail)
+	}
+	started.Stop()
+}
+
+func TestStartedStop_KillsAfterGrace(t *testing.T) {
+	t.Parallel()
+
+	core := stubCore(t, "trap '' TERM")
+	started := startStub(t, context.Background(), Runner{CorePath: core, StopGrace: 200 * time.Millisecond})
+
+	begin := time.Now()
+	started.Stop()
+	if d := time.Since(begin); d < 200*time.Millisecond || d > 3*time.Second {
+		t.Fatalf("Stop() took %v, want about the grace period", d)
+	}
+	if started.Cmd.ProcessState == nil || started.Cmd.ProcessState.Success() {
+		t.Fatalf("ProcessState = %v, want killed", started.Cmd.ProcessState)
+	}
+	if _, err := os.Stat(started.Dir); !os.IsNotExist(err) {
+		t.Fatalf("temp dir still exists after Stop: %v", err)
+	}
+}
+
+func TestStartedStop_KeepTempDir(t *testing.T) {
+	t.Parallel()
+
+	core := stubCore(t, "")
+	started := startStub(t, context.Background(), Runner{CorePath: core, KeepTempDir: true})
+	started.Stop()
+	t.Cleanup(func() { os.RemoveAll(started.Dir) })
+	if _, err := os.Stat(started.ConfigPath); err != nil {
+		t.Fatalf("config removed despite KeepTempDir: %v", err)
+	}
+}
+
+func TestStarted_ContextCancelCleansUp(t *testing.T) {
+	t.Parallel()
+
+	core := stubCore(t, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	started := startStub(t, ctx, Runner{CorePath: core, StopGrace: time.Second})
+	cancel()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for {
+		_, err := os.Stat(started.Dir)
+		if os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("temp dir not removed after cancel: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	started.Stop()
+	if started.Cmd.ProcessState == nil {
+		t.Fatal("core still running after cancel")
+	}
+}
+
+func TestRunnerStart_ConfigErrorRemovesDir(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	core := filepath.Join(t.TempDir(), "xray")
+	script := "#!/bin/sh\nif [ \"$1\" = \"version\" ]; then echo 'Xray 25.1.1'; exit 0; fi\necho 'invalid user id' >&2\nexit 23\n"
+	if err := os.WriteFile(core, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	r := Runner{CorePath: core, Port: 1080}
+	var cfgErr *ConfigError
+	if _, err := r.Start(context.Background(), map[string]any{"tag": "proxy", "protocol": "freedom"}); !errors.As(err, &cfgErr) {
+		t.Fatalf("Start() error = %v, want *ConfigError", err)
+	}
+	if left, _ := filepath.Glob(filepath.Join(tmp, "proxy-node-*")); len(left) != 0 {
+		t.Fatalf("rejected config left on disk: %v", left)
+	}
+}
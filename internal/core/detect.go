@@ -0,0 +1,333 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Flavor is the core family, which decides command-line syntax and which
+// config features exist at all.
+type Flavor string
+
+const (
+	FlavorUnknown Flavor = ""
+	FlavorXray    Flavor = "xray"
+	// FlavorV2Ray is V2Ray/v2fly 4.x with the flat -config/-test flags.
+	FlavorV2Ray Flavor = "v2ray"
+	// FlavorV2RayV5 is v2fly 5.x with run/test subcommands.
+	FlavorV2RayV5 Flavor = "v5"
+)
+
+// Version is a core release number.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// AtLeast reports whether v is o or newer.
+func (v Version) AtLeast(o Version) bool {
+	if v.Major != o.Major {
+		return v.Major > o.Major
+	}
+	if v.Minor != o.Minor {
+		return v.Minor > o.Minor
+	}
+	return v.Patch >= o.Patch
+}
+
+// Capability names an optional config feature proxy-node may generate.
+type Capability string
+
+const (
+	CapReality  Capability = "reality"
+	CapVision   Capability = "xtls-rprx-vision"
+	CapMLDSA65  Capability = "mldsa65Verify"
+	CapHysteria Capability = "hysteria"
+)
+
+// capabilities lists, per flavor, the first release supporting each feature.
+// V2Ray has none of them.
+var capabilities = map[Flavor]map[Capability]Version{
+	FlavorXray: {
+		CapVision:   {1, 7, 0},
+		CapReality:  {1, 8, 0},
+		CapMLDSA65:  {25, 7, 26},
+		CapHysteria: {25, 12, 8},
+	},
+}
+
+// Info describes a core binary as reported by its version command.
+type Info struct {
+	Path    string
+	Flavor  Flavor
+	Version Version
+	// Banner is the first line of the version output.
+	Banner string
+}
+
+// Known reports whether the flavor was recognised. Unknown cores are not
+// checked against the capability matrix.
+func (i *Info) Known() bool {
+	return i != nil && i.Flavor != FlavorUnknown
+}
+
+// Supports reports whether the core understands c. Unknown cores are assumed
+// to support everything.
+func (i *Info) Supports(c Capability) bool {
+	if !i.Known() {
+		return true
+	}
+	since, ok := capabilities[i.Flavor][c]
+	return ok && i.Version.AtLeast(since)
+}
+
+// Capabilities returns the supported features in a stable order.
+func (i *Info) Capabilities() []Capability {
+	var out []Capability
+	for _, c := range []Capability{CapReality, CapVision, CapMLDSA65, CapHysteria} {
+		if i.Supports(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (i *Info) String() string {
+	if !i.Known() {
+		return "unknown core"
+	}
+	return fmt.Sprintf("%s %s", i.Flavor, i.Version)
+}
+
+// UnsupportedError reports an outbound that needs a feature the core lacks.
+type UnsupportedError struct {
+	Core    *Info
+	Feature Capability
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("%s does not support %s (install a newer Xray core)", e.Core, e.Feature)
+}
+
+var (
+	bannerPattern  = regexp.MustCompile(`(?i)^(xray|v2ray)\s+v?(\d+)\.(\d+)(?:\.(\d+))?`)
+	versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+)
+
+// ParseVersion reads a release tag such as "v25.1.1" or "1.8".
+func ParseVersion(tag string) (Version, bool) {
+	m := versionPattern.FindStringSubmatch(strings.TrimSpace(tag))
+	if m == nil {
+		return Version{}, false
+	}
+	return versionFromMatch(m[1], m[2], m[3]), true
+}
+
+func versionFromMatch(major, minor, patch string) Version {
+	var v Version
+	v.Major, _ = strconv.Atoi(major)
+	v.Minor, _ = strconv.Atoi(minor)
+	if patch != "" {
+		v.Patch, _ = strconv.Atoi(patch)
+	}
+	return v
+}
+
+// parseBanner reads flavor and version from a version command's first line,
+// e.g. "Xray 25.1.1 (Xray, Penetrates Everything.) ..." or
+// "V2Ray 5.20.0 (V2Fly, a community-driven edition of V2Ray.) ...".
+func parseBanner(line string) (Flavor, Version, bool) {
+	m := bannerPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return FlavorUnknown, Version{}, false
+	}
+	v := versionFromMatch(m[2], m[3], m[4])
+	switch {
+	case strings.EqualFold(m[1], "xray"):
+		return FlavorXray, v, true
+	case v.Major >= 5:
+		return FlavorV2RayV5, v, true
+	default:
+		return FlavorV2Ray, v, true
+	}
+}
+
+type detectKey struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+type detectResult struct {
+	info *Info
+	err  error
+}
+
+var detectCache sync.Map
+
+// detectTimeout bounds each version command so a binary that ignores it (or
+// starts serving instead) cannot stall Start.
+const detectTimeout = 2 * time.Second
+
+// Detect runs the core's version command ("version", then "-version" for
+// V2Ray 4.x) and parses flavor and version. Results are cached per binary
+// until it changes on disk. A core whose output is not recognised yields an
+// Info with FlavorUnknown and an error.
+func Detect(ctx context.Context, path string) (*Info, error) {
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("detect core: %w", err)
+	}
+	key := detectKey{path: path, size: st.Size(), modTime: st.ModTime()}
+	if v, ok := detectCache.Load(key); ok {
+		res := v.(detectResult)
+		return res.info, res.err
+	}
+
+	info, err := detect(ctx, path)
+	if ctx.Err() == nil {
+		detectCache.Store(key, detectResult{info: info, err: err})
+	}
+	return info, err
+}
+
+func detect(ctx context.Context, path string) (*Info, error) {
+	info := &Info{Path: path}
+	var lastErr error
+	for _, arg := range []string{"version", "-version"} {
+		cmdCtx, cancel := context.WithTimeout(ctx, detectTimeout)
+		out, err := exec.CommandContext(cmdCtx, path, arg).Output()
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		line, _, _ := strings.Cut(strings.TrimSpace(string(out)), "\n")
+		info.Banner = strings.TrimSpace(line)
+		if flavor, version, ok := parseBanner(line); ok {
+			info.Flavor, info.Version = flavor, version
+			return info, nil
+		}
+	}
+	if ctx.Err() != nil {
+		return info, fmt.Errorf("detect core: %w", ctx.Err())
+	}
+	if lastErr == nil {
+		lastErr = errors.New("unrecognised version output")
+	}
+	return info, fmt.Errorf("detect core %s: %w", path, lastErr)
+}
+
+// Adapt returns outbound as it should be sent to the core: features the core
+// lacks but the link can work without (mldsa65Verify) are dropped from a
+// copy and listed in dropped; required ones fail with *UnsupportedError.
+func (i *Info) Adapt(outbound map[string]any) (adapted map[string]any, dropped []Capability, err error) {
+	if !i.Known() {
+		return outbound, nil, nil
+	}
+	if protocol, _ := outbound["protocol"].(string); protocol == "hysteria" && !i.Supports(CapHysteria) {
+		return nil, nil, &UnsupportedError{Core: i, Feature: CapHysteria}
+	}
+	if usesVision(outbound) && !i.Supports(CapVision) {
+		return nil, nil, &UnsupportedError{Core: i, Feature: CapVision}
+	}
+
+	stream, _ := outbound["streamSettings"].(map[string]any)
+	if security, _ := stream["security"].(string); !strings.EqualFold(security, "reality") {
+		return outbound, nil, nil
+	}
+	if !i.Supports(CapReality) {
+		return nil, nil, &UnsupportedError{Core: i, Feature: CapReality}
+	}
+	reality, _ := stream["realitySettings"].(map[string]any)
+	if _, ok := reality[string(CapMLDSA65)]; !ok || i.Supports(CapMLDSA65) {
+		return outbound, nil, nil
+	}
+
+	trimmed := make(map[string]any, len(reality))
+	for k, v := range reality {
+		if k != string(CapMLDSA65) {
+			trimmed[k] = v
+		}
+	}
+	newStream := make(map[string]any, len(stream))
+	for k, v := range stream {
+		newStream[k] = v
+	}
+	newStream["realitySettings"] = trimmed
+	adapted = make(map[string]any, len(outbound))
+	for k, v := range outbound {
+		adapted[k] = v
+	}
+	adapted["streamSettings"] = newStream
+	return adapted, []Capability{CapMLDSA65}, nil
+}
+
+// usesVision reports whether any VLESS user or Trojan server sets a vision flow.
+func usesVision(outbound map[string]any) bool {
+	settings, _ := outbound["settings"].(map[string]any)
+	var entries []map[string]any
+	if vnext, ok := settings["vnext"].([]any); ok {
+		for _, v := range vnext {
+			server, _ := v.(map[string]any)
+			users, _ := server["users"].([]any)
+			for _, u := range users {
+				if m, ok := u.(map[string]any); ok {
+					entries = append(entries, m)
+				}
+			}
+		}
+	}
+	if servers, ok := settings["servers"].([]any); ok {
+		for _, s := range servers {
+			if m, ok := s.(map[string]any); ok {
+				entries = append(entries, m)
+			}
+		}
+	}
+	for _, e := range entries {
+		if flow, _ := e["flow"].(string); strings.HasPrefix(strings.ToLower(flow), string(CapVision)) {
+			return true
+		}
+	}
+	return false
+}
+
+// commandArgs returns the command line running (or, with test, checking)
+// configPath. Detected cores get their flavor's syntax; unknown ones fall back
+// to guessing from the binary name.
+func commandArgs(info *Info, corePath, configPath string, test bool) []string {
+	flavor := FlavorUnknown
+	if info != nil {
+		flavor = info.Flavor
+	}
+	switch {
+	case flavor == FlavorXray && test:
+		return []string{"run", "-test", "-c", configPath}
+	case flavor == FlavorXray:
+		return []string{"run", "-c", configPath}
+	case flavor == FlavorV2RayV5 && test:
+		return []string{"test", "-c", configPath}
+	case flavor == FlavorV2RayV5:
+		return []string{"run", "-c", configPath}
+	case flavor == FlavorV2Ray && test:
+		return []string{"-test", "-config", configPath}
+	case flavor == FlavorV2Ray:
+		return []string{"-config", configPath}
+	case test:
+		return coreTestArgs(corePath, configPath)
+	default:
+		return coreArgs(corePath, configPath)
+	}
+}
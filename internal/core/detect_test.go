@@ -0,0 +1,188 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseBanner(t *testing.T) {
+	cases := []struct {
+		line    string
+		flavor  Flavor
+		version Version
+	}{
+		{"Xray 25.1.1 (Xray, Penetrates Everything.) 6a2ea7b (go1.23.4 linux/amd64)", FlavorXray, Version{25, 1, 1}},
+		{"Xray 1.8.4 (Xray, Penetrates Everything.) Custom (go1.21.1 linux/amd64)", FlavorXray, Version{1, 8, 4}},
+		{"V2Ray 5.20.0 (V2Fly, a community-driven edition of V2Ray.) Custom (go1.23.1 linux/amd64)", FlavorV2RayV5, Version{5, 20, 0}},
+		{"V2Ray 4.45.2 (V2Fly, a community-driven edition of V2Ray.) Custom (go1.18.1 linux/amd64)", FlavorV2Ray, Version{4, 45, 2}},
+		{"v2ray v4.31", FlavorV2Ray, Version{4, 31, 0}},
+	}
+	for _, c := range cases {
+		flavor, version, ok := parseBanner(c.line)
+		if !ok || flavor != c.flavor || version != c.version {
+			t.Fatalf("parseBanner(%q) = %q, %v, %v", c.line, flavor, version, ok)
+		}
+	}
+	if _, _, ok := parseBanner("sing-box version 1.9.0"); ok {
+		t.Fatal("parseBanner(sing-box) ok = true, want false")
+	}
+}
+
+func TestInfoSupports(t *testing.T) {
+	xrayOld := &Info{Flavor: FlavorXray, Version: Version{1, 7, 5}}
+	xrayMid := &Info{Flavor: FlavorXray, Version: Version{25, 8, 3}}
+	xrayNew := &Info{Flavor: FlavorXray, Version: Version{25, 12, 8}}
+	v5 := &Info{Flavor: FlavorV2RayV5, Version: Version{5, 20, 0}}
+
+	if xrayOld.Supports(CapReality) || !xrayOld.Supports(CapVision) {
+		t.Fatalf("xray 1.7.5 capabilities = %v", xrayOld.Capabilities())
+	}
+	if got := xrayNew.Capabilities(); !reflect.DeepEqual(got, []Capability{CapReality, CapVision, CapMLDSA65, CapHysteria}) {
+		t.Fatalf("xray 25.12.8 capabilities = %v", got)
+	}
+	if got := xrayMid.Capabilities(); !reflect.DeepEqual(got, []Capability{CapReality, CapVision, CapMLDSA65}) {
+		t.Fatalf("xray 25.8.3 capabilities = %v, want no hysteria", got)
+	}
+	if got := v5.Capabilities(); len(got) != 0 {
+		t.Fatalf("v2ray 5 capabilities = %v, want none", got)
+	}
+	var unknown *Info
+	if !unknown.Supports(CapReality) {
+		t.Fatal("unknown core should not be restricted")
+	}
+}
+
+func realityOutbound(pqv string) map[string]any {
+	reality := map[string]any{"publicKey": "pbk", "serverName": "example.com"}
+	if pqv != "" {
+		reality["mldsa65Verify"] = pqv
+	}
+	return map[string]any{
+		"protocol": "vless",
+		"settings": map[string]any{"vnext": []any{map[string]any{
+			"address": "example.com",
+			"port":    443,
+			"users":   []any{map[string]any{"id": "id", "flow": "xtls-rprx-vision"}},
+		}}},
+		"streamSettings": map[string]any{"network": "tcp", "security": "reality", "realitySettings": reality},
+	}
+}
+
+func TestInfoAdapt(t *testing.T) {
+	v4 := &Info{Flavor: FlavorV2Ray, Version: Version{4, 45, 2}}
+	_, _, err := v4.Adapt(realityOutbound(""))
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) || unsupported.Feature != CapVision {
+		t.Fatalf("Adapt(v4) error = %v, want vision unsupported", err)
+	}
+
+	xray := &Info{Flavor: FlavorXray, Version: Version{1, 8, 24}}
+	ob := realityOutbound("pq-key")
+	adapted, dropped, err := xray.Adapt(ob)
+	if err != nil {
+		t.Fatalf("Adapt(xray 1.8) error = %v", err)
+	}
+	if !reflect.DeepEqual(dropped, []Capability{CapMLDSA65}) {
+		t.Fatalf("dropped = %v", dropped)
+	}
+	reality := adapted["streamSettings"].(map[string]any)["realitySettings"].(map[string]any)
+	if _, ok := reality["mldsa65Verify"]; ok {
+		t.Fatal("mldsa65Verify kept for a core without support")
+	}
+	if _, ok := ob["streamSettings"].(map[string]any)["realitySettings"].(map[string]any)["mldsa65Verify"]; !ok {
+		t.Fatal("Adapt modified the caller's outbound")
+	}
+
+	newer := &Info{Flavor: FlavorXray, Version: Version{25, 7, 26}}
+	if _, dropped, err := newer.Adapt(realityOutbound("pq-key")); err != nil || len(dropped) != 0 {
+		t.Fatalf("Adapt(xray 25.7.26) = %v, %v", dropped, err)
+	}
+
+	v5 := &Info{Flavor: FlavorV2RayV5, Version: Version{5, 20, 0}}
+	if _, _, err := v5.Adapt(map[string]any{"protocol": "hysteria"}); !errors.As(err, &unsupported) || unsupported.Feature != CapHysteria {
+		t.Fatalf("Adapt(v5, hysteria) error = %v", err)
+	}
+	if _, _, err := xray.Adapt(map[string]any{"protocol": "hysteria"}); !errors.As(err, &unsupported) || unsupported.Feature != CapHysteria {
+		t.Fatalf("Adapt(xray 1.8, hysteria) error = %v", err)
+	}
+}
+
+func TestDetect_StubCores(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	v4 := filepath.Join(dir, "v2ray")
+	script := "#!/bin/sh\nif [ \"$1\" = \"-version\" ]; then echo 'V2Ray 4.45.2 (V2Fly, a community-driven edition of V2Ray.)'; exit 0; fi\necho 'unknown command' >&2\nexit 1\n"
+	if err := os.WriteFile(v4, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Detect(context.Background(), v4)
+	if err != nil {
+		t.Fatalf("Detect(v4) error = %v", err)
+	}
+	if info.Flavor != FlavorV2Ray || info.Version != (Version{4, 45, 2}) {
+		t.Fatalf("Detect(v4) = %+v", info)
+	}
+
+	other := filepath.Join(dir, "core")
+	if err := os.WriteFile(other, []byte("#!/bin/sh\necho 'something else'\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	info, err = Detect(context.Background(), other)
+	if err == nil || info.Known() {
+		t.Fatalf("Detect(other) = %+v, %v, want unknown and error", info, err)
+	}
+}
+
+func TestRunnerStart_RefusesUnsupportedFeature(t *testing.T) {
+	t.Parallel()
+
+	r := Runner{
+		CorePath: "/nonexistent/v2ray",
+		Port:     1080,
+		Core:     &Info{Flavor: FlavorV2RayV5, Version: Version{5, 20, 0}},
+	}
+	ob := realityOutbound("")
+	ob["settings"].(map[string]any)["vnext"].([]any)[0].(map[string]any)["users"] = []any{map[string]any{"id": "id"}}
+	_, err := r.Start(context.Background(), ob)
+	var unsupported *UnsupportedError
+	if !errors.As(err, &unsupported) || unsupported.Feature != CapReality {
+		t.Fatalf("Start() error = %v, want reality unsupported", err)
+	}
+}
+
+func TestCommandArgs(t *testing.T) {
+	v5 := &Info{Flavor: FlavorV2RayV5}
+	if got := commandArgs(v5, "/opt/v2ray", "c.json", false); !reflect.DeepEqual(got, []string{"run", "-c", "c.json"}) {
+		t.Fatalf("commandArgs(v5) = %v", got)
+	}
+	if got := commandArgs(v5, "/opt/v2ray", "c.json", true); !reflect.DeepEqual(got, []string{"test", "-c", "c.json"}) {
+		t.Fatalf("commandArgs(v5, test) = %v", got)
+	}
+	if got := commandArgs(nil, "/opt/xray", "c.json", false); !reflect.DeepEqual(got, coreArgs("/opt/xray", "c.json")) {
+		t.Fatalf("commandArgs(unknown) = %v", got)
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	cases := []struct {
+		tag  string
+		want Version
+		ok   bool
+	}{
+		{"v26.2.6", Version{26, 2, 6}, true},
+		{"1.8", Version{1, 8, 0}, true},
+		{"v5.20.0-rc1", Version{5, 20, 0}, true},
+		{"latest", Version{}, false},
+	}
+	for _, c := range cases {
+		got, ok := ParseVersion(c.tag)
+		if got != c.want || ok != c.ok {
+			t.Fatalf("ParseVersion(%q) = %v, %v", c.tag, got, ok)
+		}
+	}
+}
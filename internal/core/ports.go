@@ -0,0 +1,102 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// portRetries is how often StartReady moves to a fresh port after the core
+// found the one it was given taken.
+const portRetries = 3
+
+// FreePorts returns n distinct loopback ports that were free a moment ago.
+// All of them are bound to :0 at once, so the kernel hands out distinct
+// ports, then released for the core to take. Another process can still grab
+// one in between; StartReady detects that from the core log and retries.
+func FreePorts(n int) ([]int, error) {
+	listeners := make([]net.Listener, 0, n)
+	defer func() {
+		for _, ln := range listeners {
+			_ = ln.Close()
+		}
+	}()
+	ports := make([]int, 0, n)
+	for i := 0; i < n; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return nil, fmt.Errorf("reserve local port: %w", err)
+		}
+		listeners = append(listeners, ln)
+		ports = append(ports, ln.Addr().(*net.TCPAddr).Port)
+	}
+	return ports, nil
+}
+
+// FreePort returns a single free loopback port, see FreePorts.
+func FreePort() (int, error) {
+	ports, err := FreePorts(1)
+	if err != nil {
+		return 0, err
+	}
+	return ports[0], nil
+}
+
+// AddrInUse reports whether the core failed because its port was taken.
+func (e *ReadyError) AddrInUse() bool {
+	reason := strings.ToLower(e.Reason)
+	return strings.Contains(reason, "address already in use") ||
+		strings.Contains(reason, "only one usage of each socket address")
+}
+
+// StartReady is Start followed by WaitReady. With r.Port zero the core gets
+// a free port, and another one whenever it reports that port taken. A core
+// that does not become ready is stopped and returned along with the
+// *ReadyError, so that its log tails can still be read; other errors come
+// with a nil *Started.
+func (r Runner) StartReady(ctx context.Context, outbound map[string]any, timeout time.Duration) (*Started, error) {
+	var fixed []int
+	if r.Port != 0 {
+		fixed = []int{r.Port}
+	}
+	return startReady(ctx, fixed, 1, timeout, func(ports []int) (*Started, error) {
+		r.Port = ports[0]
+		return r.Start(ctx, outbound)
+	})
+}
+
+// StartManyReady is StartReady for StartMany, with one free port per
+// outbound.
+func (r Runner) StartManyReady(ctx context.Context, outbounds []map[string]any, timeout time.Duration) (*Started, error) {
+	return startReady(ctx, nil, len(outbounds), timeout, func(ports []int) (*Started, error) {
+		return r.StartMany(ctx, outbounds, ports)
+	})
+}
+
+func startReady(ctx context.Context, fixed []int, n int, timeout time.Duration, start func(ports []int) (*Started, error)) (*Started, error) {
+	for attempt := 0; ; attempt++ {
+		ports := fixed
+		if ports == nil {
+			var err error
+			if ports, err = FreePorts(n); err != nil {
+				return nil, err
+			}
+		}
+		started, err := start(ports)
+		if err != nil {
+			return nil, err
+		}
+		err = started.WaitReady(ctx, timeout)
+		if err == nil {
+			return started, nil
+		}
+		started.Stop()
+		var re *ReadyError
+		if fixed != nil || attempt >= portRetries || !errors.As(err, &re) || !re.AddrInUse() {
+			return started, err
+		}
+	}
+}
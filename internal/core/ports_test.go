@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFreePorts_Distinct(t *testing.T) {
+	t.Parallel()
+
+	ports, err := FreePorts(16)
+	if err != nil {
+		t.Fatalf("FreePorts() error = %v", err)
+	}
+	seen := map[int]bool{}
+	for _, p := range ports {
+		if p <= 0 || seen[p] {
+			t.Fatalf("ports = %v, want distinct positive ports", ports)
+		}
+		seen[p] = true
+	}
+}
+
+// takenOnceCore reports its port taken on the first run and starts on the
+// next one, recording every config it was started with.
+func takenOnceCore(t *testing.T) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	runs := filepath.Join(dir, "runs")
+	path := scriptCore(t, "grep '\"port\"' \"$3\" >> "+runs+"\n"+
+		"if [ ! -f "+dir+"/taken ]; then touch "+dir+"/taken; echo 'Failed to start: listen tcp 127.0.0.1: bind: address already in use'; exit 23; fi\n"+
+		"echo 'Xray 25.1.1 started'\n"+
+		"while :; do sleep 0.05; done")
+	return path, runs
+}
+
+func TestRunnerStartReady_RetriesTakenPort(t *testing.T) {
+	t.Parallel()
+
+	corePath, runs := takenOnceCore(t)
+	r := Runner{CorePath: corePath, StopGrace: 100 * time.Millisecond}
+	started, err := r.StartReady(context.Background(), map[string]any{"tag": "proxy", "protocol": "freedom"}, 5*time.Second)
+	if err != nil {
+		t.Fatalf("StartReady() error = %v", err)
+	}
+	defer started.Stop()
+	b, _ := os.ReadFile(runs)
+	if lines := strings.Split(strings.TrimSpace(string(b)), "\n"); len(lines) != 2 || lines[0] == lines[1] {
+		t.Fatalf("core runs = %q, want a second run on another port", b)
+	}
+	if started.Routes[0].Port == 0 {
+		t.Fatal("Routes[0].Port not set")
+	}
+}
+
+func TestRunnerStartReady_FixedPortIsNotMoved(t *testing.T) {
+	t.Parallel()
+
+	corePath, _ := takenOnceCore(t)
+	r := Runner{CorePath: corePath, Port: 1080}
+	started, err := r.StartReady(context.Background(), map[string]any{"tag": "proxy", "protocol": "freedom"}, 5*time.Second)
+	var re *ReadyError
+	if !errors.As(err, &re) || !re.AddrInUse() || started == nil {
+		t.Fatalf("StartReady() = %v, %v; want the taken port reported", started, err)
+	}
+	if !strings.Contains(started.ReadLogTail(), "address already in use") {
+		t.Fatalf("log tail = %q", started.ReadLogTail())
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// readyPoll is how often WaitReady rereads the error log.
+const readyPoll = 50 * time.Millisecond
+
+// fatalExitWait is how long a core that logged a fatal error gets to exit.
+const fatalExitWait = 500 * time.Millisecond
+
+// ReadyError explains why a core did not become ready, in the core's own
+// words where it gave any.
+type ReadyError struct {
+	// Reason is the fatal line from the core log or, failing that, the last
+	// line it logged.
+	Reason string
+	// Exited is set when the process ended during startup.
+	Exited   bool
+	ExitCode int
+	// Err is the context error when the wait timed out or was cancelled.
+	Err error
+}
+
+func (e *ReadyError) Error() string {
+	var msg string
+	switch {
+	case e.Exited:
+		msg = fmt.Sprintf("core exited with code %d", e.ExitCode)
+	case e.Err != nil:
+		msg = fmt.Sprintf("core did not report started: %v", e.Err)
+	default:
+		msg = "core failed to start"
+	}
+	if e.Reason == "" {
+		return msg
+	}
+	return msg + ": " + e.Reason
+}
+
+func (e *ReadyError) Unwrap() error { return e.Err }
+
+// WaitReady blocks until the core logs that it started, and fails as soon
+// as it logs a fatal error or exits, so a bad config or a taken port is
+// reported right away instead of after the timeout. Cores running at a log
+// level that hides the started line are ready once every inbound accepts
+// connections. A zero timeout waits as long as ctx allows.
+func (s *Started) WaitReady(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	ticker := time.NewTicker(readyPoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.exited:
+			return &ReadyError{Reason: failureReason(s.ReadLogTail()), Exited: true, ExitCode: s.ExitCode()}
+		default:
+		}
+		tail := s.ReadLogTail()
+		for _, line := range strings.Split(tail, "\n") {
+			line = strings.TrimSpace(line)
+			if isFatalLine(line) {
+				return s.fatal(line)
+			}
+			if strings.HasSuffix(line, "started") {
+				return nil
+			}
+		}
+		if s.quietLog && s.inboundsOpen() {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return &ReadyError{Reason: failureReason(tail), Err: ctx.Err()}
+		case <-s.exited:
+		case <-ticker.C:
+		}
+	}
+}
+
+// fatal reports a fatal log line, with the exit code when the core exits
+// shortly after logging it, as cores usually do.
+func (s *Started) fatal(line string) error {
+	select {
+	case <-s.exited:
+		return &ReadyError{Reason: line, Exited: true, ExitCode: s.ExitCode()}
+	case <-time.After(fatalExitWait):
+		return &ReadyError{Reason: line}
+	}
+}
+
+func (s *Started) inboundsOpen() bool {
+	for _, route := range s.Routes {
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(s.dialHost, strconv.Itoa(route.Port)), readyPoll)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+	}
+	return len(s.Routes) > 0
+}
+
+// isFatalLine matches how Xray and V2Ray report that they gave up starting.
+func isFatalLine(line string) bool {
+	return strings.Contains(line, "Failed to start") ||
+		strings.HasPrefix(line, "panic:") ||
+		strings.HasPrefix(line, "fatal error:")
+}
+
+// failureReason picks the line of a log tail that best explains a failure.
+func failureReason(tail string) string {
+	var last string
+	for _, line := range strings.Split(tail, "\n") {
+		line = strings.TrimSpace(line)
+		if isFatalLine(line) {
+			return line
+		}
+		if line != "" {
+			last = line
+		}
+	}
+	return last
+}
+
+// hidesStartedLine reports whether a log level drops the core's "started"
+// line, which Xray and V2Ray log as a warning.
+func hidesStartedLine(level string) bool {
+	switch strings.ToLower(level) {
+	case "error", "none":
+		return true
+	}
+	return false
+}
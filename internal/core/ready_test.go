@@ -0,0 +1,83 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStartedWaitReady(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name   string
+		script string
+		exited bool
+		code   int
+		reason string
+	}{
+		{"started", "echo '2025/01/01 [Warning] core: Xray 25.1.1 started'; sleep 5", false, 0, ""},
+		{"port in use", "echo 'Failed to start: app/proxyman/inbound: failed to listen TCP on 1080 > listen tcp 127.0.0.1:1080: bind: address already in use'; exit 23", true, 23, "address already in use"},
+		{"early exit", "echo 'infra/conf: unknown field \"flw\"'; exit 1", true, 1, `unknown field "flw"`},
+		{"fatal while running", "echo 'panic: invalid user id'; sleep 5", false, 0, "panic: invalid user id"},
+	}
+	for _, c := range cases {
+		started := startScript(t, c.script)
+		begin := time.Now()
+		err := started.WaitReady(context.Background(), 5*time.Second)
+		started.Stop()
+		if d := time.Since(begin); d > 2*time.Second {
+			t.Fatalf("%s: WaitReady() took %v", c.name, d)
+		}
+		if c.reason == "" {
+			if err != nil {
+				t.Fatalf("%s: WaitReady() error = %v", c.name, err)
+			}
+			continue
+		}
+		var re *ReadyError
+		if !errors.As(err, &re) || re.Exited != c.exited || re.ExitCode != c.code || !strings.Contains(re.Reason, c.reason) {
+			t.Fatalf("%s: WaitReady() error = %#v", c.name, err)
+		}
+	}
+}
+
+func TestStartedWaitReady_Timeout(t *testing.T) {
+	t.Parallel()
+
+	started := startScript(t, "sleep 5")
+	defer started.Stop()
+	err := started.WaitReady(context.Background(), 200*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitReady() error = %v, want deadline exceeded", err)
+	}
+}
+
+func TestFailureReason(t *testing.T) {
+	cases := []struct {
+		tail string
+		want string
+	}{
+		{"", ""},
+		{"a\nb\n\n", "b"},
+		{"x\nFailed to start: main: bad config\nmore\n", "Failed to start: main: bad config"},
+	}
+	for _, c := range cases {
+		if got := failureReason(c.tail); got != c.want {
+			t.Fatalf("failureReason(%q) = %q, want %q", c.tail, got, c.want)
+		}
+	}
+}
+
+// startScript starts a scriptCore running script.
+func startScript(t *testing.T, script string) *Started {
+	t.Helper()
+	r := Runner{CorePath: scriptCore(t, script), Port: 1080, StopGrace: 100 * time.Millisecond}
+	started, err := r.Start(context.Background(), map[string]any{"tag": "proxy", "protocol": "freedom"})
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	return started
+}
@@ -0,0 +1,204 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// EventKind names a step in a supervised core's life.
+type EventKind string
+
+const (
+	EventStarted    EventKind = "started"
+	EventExited     EventKind = "exited"
+	EventRestarting EventKind = "restarting"
+)
+
+// Event is reported by a Supervisor whenever its core starts, exits or is
+// about to be restarted.
+type Event struct {
+	Kind EventKind
+	Time time.Time
+	// Restarts counts restarts so far; 0 for the first start.
+	Restarts int
+	PID      int
+	// ExitCode and LogTail describe an exit. ExitCode is -1 when the core
+	// was killed by a signal or could not be started at all (Err says why).
+	ExitCode int
+	LogTail  string
+	Err      error
+	// Backoff is the wait before the restart announced by EventRestarting.
+	Backoff time.Duration
+}
+
+const (
+	defaultMinBackoff  = time.Second
+	defaultMaxBackoff  = 30 * time.Second
+	defaultStableAfter = time.Minute
+	// defaultReadyTimeout bounds WaitReady after a restart when the Runner
+	// sets no Timeout.
+	defaultReadyTimeout = 20 * time.Second
+)
+
+// Supervisor keeps a single-outbound core running: whenever the process
+// exits it is started again, waiting MinBackoff, then twice as long after
+// each further failure up to MaxBackoff. A restarted core only counts as
+// started once WaitReady succeeds; one that fails before that is reported as
+// exited and retried. A core that stayed up for StableAfter starts over at
+// MinBackoff.
+type Supervisor struct {
+	Runner   Runner
+	Outbound map[string]any
+	// OnEvent receives lifecycle events from the supervising goroutine.
+	OnEvent func(Event)
+
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+	StableAfter time.Duration
+
+	mu      sync.Mutex
+	current *Started
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// Start launches the core with Runner.StartReady, so a zero Runner.Port
+// gets a free port, and a new one on every restart, and begins supervising
+// it. Errors from the first start are returned as StartReady returns them
+// and nothing is supervised; later failures only show up as events. The
+// core runs until Stop is called or ctx ends.
+func (s *Supervisor) Start(ctx context.Context) (*Started, error) {
+	if s.done != nil {
+		return nil, errors.New("supervisor already started")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	started, err := s.Runner.StartReady(ctx, s.Outbound, s.readyTimeout())
+	if err != nil {
+		cancel()
+		return started, err
+	}
+	s.mu.Lock()
+	s.current = started
+	s.cancel = cancel
+	s.done = make(chan struct{})
+	s.mu.Unlock()
+
+	s.emit(Event{Kind: EventStarted, PID: started.Cmd.Process.Pid})
+	go s.supervise(ctx, started)
+	return started, nil
+}
+
+// Current is the most recently started core. Between an exit and the next
+// successful restart it is the one that exited.
+func (s *Supervisor) Current() *Started {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.current
+}
+
+// Stop stops the core and waits for supervision to end.
+func (s *Supervisor) Stop() {
+	s.mu.Lock()
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	<-done
+}
+
+func (s *Supervisor) supervise(ctx context.Context, started *Started) {
+	defer close(s.done)
+
+	backoff := s.minBackoff()
+	restarts := 0
+	for {
+		upSince := time.Now()
+		select {
+		case <-ctx.Done():
+			started.Stop()
+			return
+		case <-started.Done():
+		}
+		if ctx.Err() != nil {
+			started.Stop()
+			return
+		}
+		tail := started.ReadLogTail()
+		started.Stop()
+		s.emit(Event{Kind: EventExited, Restarts: restarts, PID: started.Cmd.Process.Pid, ExitCode: started.ExitCode(), LogTail: tail, Err: started.Err()})
+		if time.Since(upSince) >= s.stableAfter() {
+			backoff = s.minBackoff()
+		}
+
+		for {
+			restarts++
+			s.emit(Event{Kind: EventRestarting, Restarts: restarts, Backoff: backoff})
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, s.maxBackoff())
+
+			next, err := s.Runner.StartReady(ctx, s.Outbound, s.readyTimeout())
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				ev := Event{Kind: EventExited, Restarts: restarts, ExitCode: -1, Err: err}
+				if next != nil {
+					// A core that dies while starting is an exit like any other.
+					ev.PID, ev.ExitCode, ev.LogTail = next.Cmd.Process.Pid, next.ExitCode(), next.ReadLogTail()
+				}
+				s.emit(ev)
+				continue
+			}
+			s.mu.Lock()
+			s.current = next
+			s.mu.Unlock()
+			s.emit(Event{Kind: EventStarted, Restarts: restarts, PID: next.Cmd.Process.Pid})
+			started = next
+			break
+		}
+	}
+}
+
+func (s *Supervisor) emit(ev Event) {
+	if s.OnEvent == nil {
+		return
+	}
+	ev.Time = time.Now()
+	s.OnEvent(ev)
+}
+
+func (s *Supervisor) readyTimeout() time.Duration {
+	if s.Runner.Timeout > 0 {
+		return s.Runner.Timeout
+	}
+	return defaultReadyTimeout
+}
+
+func (s *Supervisor) minBackoff() time.Duration {
+	if s.MinBackoff > 0 {
+		return s.MinBackoff
+	}
+	return defaultMinBackoff
+}
+
+func (s *Supervisor) maxBackoff() time.Duration {
+	if s.MaxBackoff > 0 {
+		return s.MaxBackoff
+	}
+	return defaultMaxBackoff
+}
+
+func (s *Supervisor) stableAfter() time.Duration {
+	if s.StableAfter > 0 {
+		return s.StableAfter
+	}
+	return defaultStableAfter
+}
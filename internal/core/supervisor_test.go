@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSupervisor_RestartsWithBackoff(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var events []Event
+	s := &Supervisor{
+		Runner:     Runner{CorePath: stubCore(t, "sleep 0.2; echo 'port already in use'; exit 3"), Port: 1080},
+		Outbound:   map[string]any{"tag": "proxy", "protocol": "freedom"},
+		MinBackoff: 20 * time.Millisecond,
+		MaxBackoff: 50 * time.Millisecond,
+		OnEvent: func(ev Event) {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		},
+	}
+	first, err := s.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 7 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d events before the deadline", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []EventKind{EventStarted, EventExited, EventRestarting, EventStarted, EventExited, EventRestarting, EventStarted}
+	for i, kind := range want {
+		if events[i].Kind != kind {
+			t.Fatalf("events[%d] = %+v, want %s", i, events[i], kind)
+		}
+	}
+	exited := events[1]
+	if exited.ExitCode != 3 || !strings.Contains(exited.LogTail, "port already in use") || exited.PID != first.Cmd.Process.Pid {
+		t.Fatalf("exited event = %+v", exited)
+	}
+	if events[2].Restarts != 1 || events[2].Backoff != 20*time.Millisecond {
+		t.Fatalf("first restarting event = %+v", events[2])
+	}
+	if events[5].Restarts != 2 || events[5].Backoff != 40*time.Millisecond {
+		t.Fatalf("second restarting event = %+v", events[5])
+	}
+	if s.Current() == first {
+		t.Fatal("Current() still returns the first core")
+	}
+
+	// Nothing is reported once Stop returns.
+	n := len(events)
+	mu.Unlock()
+	time.Sleep(150 * time.Millisecond)
+	mu.Lock()
+	if len(events) != n {
+		t.Fatalf("events after Stop: %+v", events[n:])
+	}
+}
+
+func TestSupervisor_RestartNotReadyIsAnExit(t *testing.T) {
+	t.Parallel()
+
+	// The first run comes up and exits; every restart fails before it
+	// reports started.
+	marker := filepath.Join(t.TempDir(), "ran")
+	corePath := scriptCore(t, "if [ -f "+marker+" ]; then echo 'Failed to start: invalid user id'; exit 2; fi\n"+
+		"touch "+marker+"; echo started; sleep 0.2; exit 1")
+
+	var mu sync.Mutex
+	var events []Event
+	s := &Supervisor{
+		Runner:     Runner{CorePath: corePath, Port: 1080},
+		Outbound:   map[string]any{"tag": "proxy", "protocol": "freedom"},
+		MinBackoff: 20 * time.Millisecond,
+		MaxBackoff: 20 * time.Millisecond,
+		OnEvent: func(ev Event) {
+			mu.Lock()
+			events = append(events, ev)
+			mu.Unlock()
+		},
+	}
+	if _, err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := len(events)
+		mu.Unlock()
+		if n >= 7 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d events before the deadline", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []EventKind{EventStarted, EventExited, EventRestarting, EventExited, EventRestarting, EventExited, EventRestarting}
+	for i, kind := range want {
+		if events[i].Kind != kind {
+			t.Fatalf("events[%d] = %+v, want %s", i, events[i], kind)
+		}
+	}
+	failed := events[3]
+	var re *ReadyError
+	if failed.ExitCode != 2 || !errors.As(failed.Err, &re) || !strings.Contains(failed.LogTail, "invalid user id") {
+		t.Fatalf("failed restart event = %+v", failed)
+	}
+	if events[4].Restarts != 2 {
+		t.Fatalf("failed restarts do not count: %+v", events[4])
+	}
+}
+
+func TestSupervisor_StopEndsSupervision(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var kinds []EventKind
+	s := &Supervisor{
+		Runner:   Runner{CorePath: stubCore(t, ""), Port: 1080, StopGrace: time.Second},
+		Outbound: map[string]any{"tag": "proxy", "protocol": "freedom"},
+		OnEvent: func(ev Event) {
+			mu.Lock()
+			kinds = append(kinds, ev.Kind)
+			mu.Unlock()
+		},
+	}
+	started, err := s.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	s.Stop()
+	if started.Cmd.ProcessState == nil {
+		t.Fatal("core still running after Stop")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(kinds) != 1 || kinds[0] != EventStarted {
+		t.Fatalf("events = %v, want only started", kinds)
+	}
+}
+
+func TestSupervisor_FreePortPerStart(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	var started int
+	s := &Supervisor{
+		Runner:     Runner{CorePath: stubCore(t, "sleep 0.2; exit 1")},
+		Outbound:   map[string]any{"tag": "proxy", "protocol": "freedom"},
+		MinBackoff: 20 * time.Millisecond,
+		OnEvent: func(ev Event) {
+			if ev.Kind == EventStarted {
+				mu.Lock()
+				started++
+				mu.Unlock()
+			}
+		},
+	}
+	first, err := s.Start(context.Background())
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer s.Stop()
+	if first.Routes[0].Port == 0 {
+		t.Fatal("first core got no port")
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		n := started
+		mu.Unlock()
+		if n >= 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("core was not restarted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if cur := s.Current(); cur == first || cur.Routes[0].Port == 0 {
+		t.Fatalf("Current() = %+v after restart", cur.Routes)
+	}
+}
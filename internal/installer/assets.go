@@ -0,0 +1,269 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+)
+
+// platform is the target an asset must run on. GOARM and GOAMD64 only
+// matter for arm and amd64 and cap which variants may be picked.
+type platform struct {
+	goos    string
+	goarch  string
+	goarm   int
+	goamd64 int
+}
+
+// hostPlatform describes the machine proxy-node runs on. GOARM and GOAMD64
+// come from the environment when set, otherwise from the levels this binary
+// was built for (which the CPU evidently supports).
+func hostPlatform() platform {
+	p := platform{goos: runtime.GOOS, goarch: runtime.GOARCH, goarm: 7, goamd64: 1}
+	settings := map[string]string{}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			settings[s.Key] = s.Value
+		}
+	}
+	level := func(key string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return settings[key]
+	}
+	if n, err := strconv.Atoi(strings.SplitN(level("GOARM"), ",", 2)[0]); err == nil {
+		p.goarm = n
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(level("GOAMD64"), "v")); err == nil {
+		p.goamd64 = n
+	}
+	return p
+}
+
+// assetTarget is what an asset name says about its platform, e.g.
+// Xray-linux-arm32-v7a.zip is {linux, arm, 7}.
+type assetTarget struct {
+	goos   string
+	goarch string
+	level  int // GOARM for arm, GOAMD64 for amd64, 0 when not stated
+}
+
+var osAliases = map[string]string{
+	"linux":     "linux",
+	"darwin":    "darwin",
+	"macos":     "darwin",
+	"osx":       "darwin",
+	"mac":       "darwin",
+	"windows":   "windows",
+	"win":       "windows",
+	"freebsd":   "freebsd",
+	"openbsd":   "openbsd",
+	"netbsd":    "netbsd",
+	"android":   "android",
+	"dragonfly": "dragonfly",
+}
+
+// archAliases maps the architecture token of Xray/V2Ray style names to
+// GOARCH. "64" and "32" are bare amd64/386 there; ARM always carries an
+// explicit arm32/arm64 token, so the two never collide once names are
+// split into tokens.
+var archAliases = map[string]string{
+	"64":          "amd64",
+	"amd64":       "amd64",
+	"x86_64":      "amd64",
+	"x64":         "amd64",
+	"32":          "386",
+	"386":         "386",
+	"i386":        "386",
+	"i686":        "386",
+	"x86":         "386",
+	"arm64":       "arm64",
+	"aarch64":     "arm64",
+	"arm32":       "arm",
+	"arm":         "arm",
+	"armhf":       "arm",
+	"armel":       "arm",
+	"mips":        "mips",
+	"mips32":      "mips",
+	"mipsle":      "mipsle",
+	"mips32le":    "mipsle",
+	"mips64":      "mips64",
+	"mips64le":    "mips64le",
+	"ppc64":       "ppc64",
+	"ppc64le":     "ppc64le",
+	"riscv64":     "riscv64",
+	"s390x":       "s390x",
+	"loong64":     "loong64",
+	"loongarch64": "loong64",
+}
+
+// parseAssetName reads the platform out of a release asset name. ok is false
+// for names that are not archives or that name no known OS and architecture.
+func parseAssetName(name string) (assetTarget, bool) {
+	lower := strings.ToLower(name)
+	if !isArchive(lower) {
+		return assetTarget{}, false
+	}
+	for _, ext := range []string{".zip", ".tar.gz", ".tgz"} {
+		lower = strings.TrimSuffix(lower, ext)
+	}
+	tokens := strings.FieldsFunc(lower, func(r rune) bool { return r == '-' || r == '.' })
+
+	var t assetTarget
+	for _, tok := range tokens {
+		if goos, ok := osAliases[tok]; ok && t.goos == "" {
+			t.goos = goos
+			continue
+		}
+		// The architecture follows the OS; numbers before it are versions.
+		if t.goos == "" {
+			continue
+		}
+		if t.goarch == "" {
+			if goarch, level, ok := parseArchToken(tok); ok {
+				t.goarch, t.level = goarch, level
+				continue
+			}
+		}
+		if t.goarch != "" && t.level == 0 {
+			t.level = parseLevel(t.goarch, tok)
+		}
+	}
+	return t, t.goos != "" && t.goarch != ""
+}
+
+// parseArchToken accepts plain architecture tokens as well as ones with the
+// level glued on (armv7, armv6l, amd64v3, x86_64_v3).
+func parseArchToken(tok string) (goarch string, level int, ok bool) {
+	if goarch, ok := archAliases[tok]; ok {
+		return goarch, 0, true
+	}
+	for alias, goarch := range archAliases {
+		if (goarch == "arm" || goarch == "amd64") && len(tok) > len(alias) && strings.HasPrefix(tok, alias) {
+			if level := parseLevel(goarch, strings.TrimPrefix(tok[len(alias):], "_")); level > 0 {
+				return goarch, level, true
+			}
+		}
+	}
+	return "", 0, false
+}
+
+// parseLevel reads variant suffixes: v5, v6, v7, v7a, v6l for arm and
+// v1..v4 for amd64. Anything else (v8a on arm64, softfloat, …) is 0.
+func parseLevel(goarch, tok string) int {
+	if !strings.HasPrefix(tok, "v") {
+		return 0
+	}
+	digits := strings.TrimRight(tok[1:], "al")
+	n, err := strconv.Atoi(digits)
+	if err != nil {
+		return 0
+	}
+	switch {
+	case goarch == "arm" && n >= 5 && n <= 7:
+		return n
+	case goarch == "amd64" && n >= 1 && n <= 4 && digits == tok[1:]:
+		return n
+	}
+	return 0
+}
+
+// scoreAsset rates how well t fits p; ok is false when it cannot run there.
+// Among runnable variants the highest level wins (armv7 over armv6 on a v7
+// board), and an unstated level counts as the baseline.
+func scoreAsset(t assetTarget, p platform) (score int, ok bool) {
+	if t.goos != p.goos || t.goarch != p.goarch {
+		return 0, false
+	}
+	switch t.goarch {
+	case "arm":
+		level := t.level
+		if level == 0 {
+			level = 5
+		}
+		if level > p.goarm {
+			return 0, false
+		}
+		return level * 10, true
+	case "amd64":
+		level := t.level
+		if level == 0 {
+			level = 1
+		}
+		if level > p.goamd64 {
+			return 0, false
+		}
+		return level * 10, true
+	}
+	return 10, true
+}
+
+// chooseAsset picks the release archive for p. A non-empty pattern is a
+// case-insensitive glob over asset names that overrides platform matching;
+// when several assets match it, the platform score still breaks the tie.
+func chooseAsset(assets []asset, p platform, pattern string) (asset, error) {
+	best, bestScore := -1, -1
+	for i, a := range assets {
+		name := strings.ToLower(a.Name)
+		if !isArchive(name) {
+			continue
+		}
+		t, parsed := parseAssetName(a.Name)
+		score, ok := scoreAsset(t, p)
+		if pattern != "" {
+			matched, err := path.Match(strings.ToLower(pattern), name)
+			if err != nil {
+				return asset{}, fmt.Errorf("invalid --asset pattern %q: %w", pattern, err)
+			}
+			if !matched {
+				continue
+			}
+			if !parsed || !ok {
+				score = 0
+			}
+		} else if !ok {
+			continue
+		}
+		// Prefer .zip when the same build also ships as a tarball.
+		score *= 2
+		if strings.HasSuffix(name, ".zip") {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+	if best >= 0 {
+		return assets[best], nil
+	}
+
+	var names []string
+	for _, a := range assets {
+		names = append(names, a.Name)
+	}
+	if pattern != "" {
+		return asset{}, fmt.Errorf("no release asset matches %q, assets: %s", pattern, strings.Join(names, ", "))
+	}
+	return asset{}, fmt.Errorf("no matching release asset for %s, assets: %s (use --asset to pick one)", p, strings.Join(names, ", "))
+}
+
+func (p platform) String() string {
+	switch p.goarch {
+	case "arm":
+		return fmt.Sprintf("%s/arm (GOARM=%d)", p.goos, p.goarm)
+	case "amd64":
+		if p.goamd64 > 1 {
+			return fmt.Sprintf("%s/amd64 (GOAMD64=v%d)", p.goos, p.goamd64)
+		}
+	}
+	return p.goos + "/" + p.goarch
+}
+
+func isArchive(name string) bool {
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
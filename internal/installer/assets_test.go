@@ -0,0 +1,137 @@
+package installer
+
+import (
+	"strings"
+	"testing"
+)
+
+func assetList(names ...string) []asset {
+	out := make([]asset, 0, len(names)*2)
+	for _, n := range names {
+		out = append(out, asset{Name: n}, asset{Name: n + ".dgst"})
+	}
+	return out
+}
+
+// Asset names as published by XTLS/Xray-core v25, v2fly/v2ray-core v4/v5 and
+// SagerNet/sing-box (the latter for GOAMD64 variants).
+var (
+	xrayAssets = assetList(
+		"Xray-android-amd64.zip", "Xray-android-arm64-v8a.zip",
+		"Xray-freebsd-32.zip", "Xray-freebsd-64.zip", "Xray-freebsd-arm32-v7a.zip", "Xray-freebsd-arm64-v8a.zip",
+		"Xray-linux-32.zip", "Xray-linux-64.zip",
+		"Xray-linux-arm32-v5.zip", "Xray-linux-arm32-v6.zip", "Xray-linux-arm32-v7a.zip", "Xray-linux-arm64-v8a.zip",
+		"Xray-linux-loong64.zip", "Xray-linux-mips32.zip", "Xray-linux-mips32le.zip", "Xray-linux-mips64.zip", "Xray-linux-mips64le.zip",
+		"Xray-linux-ppc64.zip", "Xray-linux-ppc64le.zip", "Xray-linux-riscv64.zip", "Xray-linux-s390x.zip",
+		"Xray-macos-64.zip", "Xray-macos-arm64-v8a.zip",
+		"Xray-openbsd-32.zip", "Xray-openbsd-64.zip",
+		"Xray-win7-32.zip", "Xray-win7-64.zip",
+		"Xray-windows-32.zip", "Xray-windows-64.zip", "Xray-windows-arm32-v7a.zip", "Xray-windows-arm64-v8a.zip",
+	)
+	v2rayAssets = assetList(
+		"v2ray-android-arm64-v8a.zip",
+		"v2ray-linux-32.zip", "v2ray-linux-64.zip",
+		"v2ray-linux-arm32-v5.zip", "v2ray-linux-arm32-v6.zip", "v2ray-linux-arm32-v7a.zip", "v2ray-linux-arm64-v8a.zip",
+		"v2ray-linux-mips32.zip", "v2ray-linux-mips32le.zip", "v2ray-linux-mips64.zip", "v2ray-linux-mips64le.zip",
+		"v2ray-linux-ppc64le.zip", "v2ray-linux-riscv64.zip", "v2ray-linux-s390x.zip",
+		"v2ray-macos-64.zip", "v2ray-macos-arm64-v8a.zip",
+		"v2ray-windows-32.zip", "v2ray-windows-64.zip", "v2ray-windows-arm32-v7a.zip", "v2ray-windows-arm64-v8a.zip",
+	)
+	singBoxAssets = []asset{
+		{Name: "sing-box-1.9.0-linux-386.tar.gz"},
+		{Name: "sing-box-1.9.0-linux-amd64.tar.gz"},
+		{Name: "sing-box-1.9.0-linux-amd64v3.tar.gz"},
+		{Name: "sing-box-1.9.0-linux-arm64.tar.gz"},
+		{Name: "sing-box-1.9.0-linux-armv6.tar.gz"},
+		{Name: "sing-box-1.9.0-linux-armv7.tar.gz"},
+		{Name: "sing-box-1.9.0-windows-amd64.zip"},
+	}
+)
+
+func TestChooseAsset(t *testing.T) {
+	cases := []struct {
+		assets  []asset
+		p       platform
+		pattern string
+		want    string
+	}{
+		{xrayAssets, platform{goos: "linux", goarch: "amd64", goamd64: 1}, "", "Xray-linux-64.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "386"}, "", "Xray-linux-32.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "arm64"}, "", "Xray-linux-arm64-v8a.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "arm", goarm: 7}, "", "Xray-linux-arm32-v7a.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "arm", goarm: 6}, "", "Xray-linux-arm32-v6.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "arm", goarm: 5}, "", "Xray-linux-arm32-v5.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "mips64le"}, "", "Xray-linux-mips64le.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "mips64"}, "", "Xray-linux-mips64.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "mipsle"}, "", "Xray-linux-mips32le.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "loong64"}, "", "Xray-linux-loong64.zip"},
+		{xrayAssets, platform{goos: "darwin", goarch: "amd64", goamd64: 1}, "", "Xray-macos-64.zip"},
+		{xrayAssets, platform{goos: "darwin", goarch: "arm64"}, "", "Xray-macos-arm64-v8a.zip"},
+		{xrayAssets, platform{goos: "windows", goarch: "amd64", goamd64: 1}, "", "Xray-windows-64.zip"},
+		{xrayAssets, platform{goos: "windows", goarch: "arm", goarm: 7}, "", "Xray-windows-arm32-v7a.zip"},
+		{xrayAssets, platform{goos: "android", goarch: "arm64"}, "", "Xray-android-arm64-v8a.zip"},
+		{xrayAssets, platform{goos: "freebsd", goarch: "amd64", goamd64: 3}, "", "Xray-freebsd-64.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "amd64", goamd64: 1}, "*win7-64*", "Xray-win7-64.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "arm", goarm: 7}, "xray-linux-arm32-v6.zip", "Xray-linux-arm32-v6.zip"},
+		{xrayAssets, platform{goos: "linux", goarch: "arm", goarm: 7}, "Xray-linux-arm32-*", "Xray-linux-arm32-v7a.zip"},
+		{v2rayAssets, platform{goos: "linux", goarch: "amd64", goamd64: 1}, "", "v2ray-linux-64.zip"},
+		{v2rayAssets, platform{goos: "linux", goarch: "arm", goarm: 7}, "", "v2ray-linux-arm32-v7a.zip"},
+		{v2rayAssets, platform{goos: "linux", goarch: "mips"}, "", "v2ray-linux-mips32.zip"},
+		{singBoxAssets, platform{goos: "linux", goarch: "amd64", goamd64: 1}, "", "sing-box-1.9.0-linux-amd64.tar.gz"},
+		{singBoxAssets, platform{goos: "linux", goarch: "amd64", goamd64: 3}, "", "sing-box-1.9.0-linux-amd64v3.tar.gz"},
+		{singBoxAssets, platform{goos: "linux", goarch: "arm", goarm: 6}, "", "sing-box-1.9.0-linux-armv6.tar.gz"},
+		{singBoxAssets, platform{goos: "linux", goarch: "arm", goarm: 7}, "", "sing-box-1.9.0-linux-armv7.tar.gz"},
+	}
+	for _, c := range cases {
+		got, err := chooseAsset(c.assets, c.p, c.pattern)
+		if err != nil || got.Name != c.want {
+			t.Fatalf("chooseAsset(%s, %q) = %q, %v; want %q", c.p, c.pattern, got.Name, err, c.want)
+		}
+	}
+}
+
+func TestChooseAsset_NoMatch(t *testing.T) {
+	cases := []struct {
+		assets  []asset
+		p       platform
+		pattern string
+		want    string
+	}{
+		// v2ray v4 ships no loong64 or ppc64 big-endian build.
+		{v2rayAssets, platform{goos: "linux", goarch: "loong64"}, "", "use --asset"},
+		{v2rayAssets, platform{goos: "linux", goarch: "ppc64"}, "", "use --asset"},
+		// An armv5 board must not get a v6/v7 build.
+		{singBoxAssets, platform{goos: "linux", goarch: "arm", goarm: 5}, "", "GOARM=5"},
+		{xrayAssets, platform{goos: "linux", goarch: "amd64"}, "*-nope-*", `no release asset matches "*-nope-*"`},
+		{xrayAssets, platform{goos: "linux", goarch: "amd64"}, "[", "invalid --asset pattern"},
+	}
+	for _, c := range cases {
+		got, err := chooseAsset(c.assets, c.p, c.pattern)
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("chooseAsset(%s, %q) = %q, %v; want error containing %q", c.p, c.pattern, got.Name, err, c.want)
+		}
+	}
+}
+
+func TestParseAssetName(t *testing.T) {
+	cases := []struct {
+		name string
+		want assetTarget
+		ok   bool
+	}{
+		{"Xray-linux-arm64-v8a.zip", assetTarget{"linux", "arm64", 0}, true},
+		{"Xray-linux-arm32-v7a.zip", assetTarget{"linux", "arm", 7}, true},
+		{"Xray-linux-64.zip", assetTarget{"linux", "amd64", 0}, true},
+		{"Xray-linux-mips32le.zip", assetTarget{"linux", "mipsle", 0}, true},
+		{"hysteria-linux-armv6l.tar.gz", assetTarget{"linux", "arm", 6}, true},
+		{"core-2.64.1-linux-x86_64_v3.tgz", assetTarget{"linux", "amd64", 3}, true},
+		{"Xray-linux-64.zip.dgst", assetTarget{}, false},
+		{"Xray-win7-64.zip", assetTarget{goarch: ""}, false},
+	}
+	for _, c := range cases {
+		got, ok := parseAssetName(c.name)
+		if ok != c.ok || (ok && got != c.want) {
+			t.Fatalf("parseAssetName(%q) = %+v, %v", c.name, got, ok)
+		}
+	}
+}
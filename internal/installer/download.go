@@ -0,0 +1,240 @@
+package installer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Progress describes a running download. Total is -1 when the server sends
+// no length; Resumed is how much was already on disk when it started, so
+// the transfer rate is (Done-Resumed)/Elapsed.
+type Progress struct {
+	Asset   string
+	Done    int64
+	Total   int64
+	Resumed int64
+	Elapsed time.Duration
+	// Cached is set on the single report for an archive taken from the
+	// cache without downloading.
+	Cached bool
+	// Finished marks the last report of a download.
+	Finished bool
+}
+
+// progressInterval throttles Progress callbacks.
+const progressInterval = 200 * time.Millisecond
+
+// DefaultCacheDir is where install-core keeps downloaded archives:
+// $XDG_CACHE_HOME/proxy-node/releases or the platform equivalent.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "proxy-node", "releases")
+}
+
+// cachePath is the cache location of a release asset, keyed by
+// repo+tag+asset so that machines sharing the directory agree on it.
+func cachePath(cacheDir, repo, tag, name string) string {
+	return filepath.Join(cacheDir, cacheKey(repo), cacheKey(tag), cacheKey(name))
+}
+
+func cacheKey(s string) string {
+	s = strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':':
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" || s == "." || s == ".." {
+		return "_"
+	}
+	return s
+}
+
+// fetchAsset makes a available locally, verified against its checksum
+// sibling unless skipVerify, and returns its path. Without a cache path it
+// downloads to tmpPath. With one, a copy already in the cache is re-verified
+// and reused, an interrupted download resumes from cached+".part", and the
+// archive only takes its cache name once verified.
+func (r remote) fetchAsset(ctx context.Context, assets []asset, a asset, cached, tmpPath string, skipVerify bool) (string, error) {
+	if cached == "" {
+		if err := r.downloadFile(ctx, a.URL, tmpPath); err != nil {
+			return "", err
+		}
+		if !skipVerify {
+			if err := r.verifyAsset(ctx, assets, a, tmpPath); err != nil {
+				return "", err
+			}
+		}
+		return tmpPath, nil
+	}
+
+	if st, err := os.Stat(cached); err == nil {
+		var verifyErr error
+		if !skipVerify {
+			verifyErr = r.verifyAsset(ctx, assets, a, cached)
+		}
+		var mismatch *ChecksumError
+		if verifyErr == nil {
+			r.report(Progress{Asset: a.Name, Done: st.Size(), Total: st.Size(), Cached: true, Finished: true})
+			return cached, nil
+		}
+		if !errors.As(verifyErr, &mismatch) {
+			return "", verifyErr
+		}
+		// Stale or tampered with: fetch it again.
+		if err := os.Remove(cached); err != nil {
+			return "", fmt.Errorf("remove cached archive: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		return "", fmt.Errorf("create cache dir: %w", err)
+	}
+	part := cached + ".part"
+	if err := r.downloadFile(ctx, a.URL, part); err != nil {
+		return "", err
+	}
+	if skipVerify {
+		// Unverified archives are used but never published to the cache.
+		// tmpPath keeps the asset name the extractor goes by.
+		if err := os.Rename(part, tmpPath); err != nil {
+			if err := copyFile(part, tmpPath, 0o644); err != nil {
+				return "", err
+			}
+			_ = os.Remove(part)
+		}
+		return tmpPath, nil
+	}
+	if err := r.verifyAsset(ctx, assets, a, part); err != nil {
+		// A corrupt resume must not be resumed again.
+		_ = os.Remove(part)
+		return "", err
+	}
+	if err := os.Rename(part, cached); err != nil {
+		return "", fmt.Errorf("store cached archive: %w", err)
+	}
+	return cached, nil
+}
+
+// downloadFile fetches rawURL into dst. When dst already holds a partial
+// download it asks for the rest with a Range request and appends; servers
+// that ignore the range get the file rewritten from the start.
+func (r remote) downloadFile(ctx context.Context, rawURL, dst string) error {
+	var offset int64
+	if st, err := os.Stat(dst); err == nil {
+		offset = st.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.assetURL(rawURL), nil)
+	if err != nil {
+		return fmt.Errorf("build download request: %w", err)
+	}
+	req.Header.Set("User-Agent", "proxy-node")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download asset: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	switch {
+	case offset > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// The partial file is complete or longer than the asset; start over.
+		if err := os.Remove(dst); err != nil {
+			return fmt.Errorf("remove partial download: %w", err)
+		}
+		return r.downloadFile(ctx, rawURL, dst)
+	case resp.StatusCode >= 300:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("download failed with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	case offset > 0 && resp.StatusCode == http.StatusPartialContent && rangeStart(resp.Header.Get("Content-Range")) == offset:
+		flags = os.O_WRONLY | os.O_APPEND
+	default:
+		offset = 0
+	}
+
+	out, err := os.OpenFile(dst, flags, 0o644)
+	if err != nil {
+		return fmt.Errorf("create download file: %w", err)
+	}
+	defer out.Close()
+
+	total := int64(-1)
+	if resp.ContentLength >= 0 {
+		total = offset + resp.ContentLength
+	}
+	name := strings.TrimSuffix(filepath.Base(dst), ".part")
+	pw := &progressWriter{
+		r:       r,
+		p:       Progress{Asset: name, Done: offset, Total: total, Resumed: offset},
+		started: time.Now(),
+	}
+	if _, err := io.Copy(out, io.TeeReader(resp.Body, pw)); err != nil {
+		return fmt.Errorf("write download file: %w", err)
+	}
+	pw.finish()
+	return nil
+}
+
+// rangeStart parses the first byte position of "bytes 100-199/200".
+func rangeStart(contentRange string) int64 {
+	spec, ok := strings.CutPrefix(contentRange, "bytes ")
+	if !ok {
+		return -1
+	}
+	first, _, ok := strings.Cut(spec, "-")
+	if !ok {
+		return -1
+	}
+	n, err := strconv.ParseInt(first, 10, 64)
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+func (r remote) report(p Progress) {
+	if r.progress != nil {
+		r.progress(p)
+	}
+}
+
+// progressWriter counts bytes passing through a download and reports them
+// at most every progressInterval.
+type progressWriter struct {
+	r       remote
+	p       Progress
+	started time.Time
+	last    time.Time
+}
+
+func (w *progressWriter) Write(b []byte) (int, error) {
+	w.p.Done += int64(len(b))
+	if now := time.Now(); now.Sub(w.last) >= progressInterval {
+		w.last = now
+		w.p.Elapsed = now.Sub(w.started)
+		w.r.report(w.p)
+	}
+	return len(b), nil
+}
+
+func (w *progressWriter) finish() {
+	w.p.Elapsed = time.Since(w.started)
+	w.p.Finished = true
+	w.r.report(w.p)
+}
@@ -0,0 +1,178 @@
+package installer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDownloadFile_ResumesPartial(t *testing.T) {
+	t.Parallel()
+
+	body := strings.Repeat("0123456789", 1000)
+	var gotRange string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotRange = r.Header.Get("Range")
+		http.ServeContent(w, r, "a.zip", time.Time{}, strings.NewReader(body))
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "a.zip.part")
+	if err := os.WriteFile(dst, []byte(body[:4000]), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var last Progress
+	r := newRemote(Options{Progress: func(p Progress) { last = p }})
+	if err := r.downloadFile(context.Background(), srv.URL+"/a.zip", dst); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	if gotRange != "bytes=4000-" {
+		t.Fatalf("Range = %q", gotRange)
+	}
+	assertFile(t, dst, body)
+	if !last.Finished || last.Asset != "a.zip" || last.Resumed != 4000 || last.Done != 10000 || last.Total != 10000 {
+		t.Fatalf("last progress = %+v", last)
+	}
+}
+
+func TestDownloadFile_RangeIgnored(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("full body"))
+	}))
+	defer srv.Close()
+
+	dst := filepath.Join(t.TempDir(), "a.zip.part")
+	if err := os.WriteFile(dst, []byte("stale partial bytes"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := newRemote(Options{}).downloadFile(context.Background(), srv.URL, dst); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	assertFile(t, dst, "full body")
+}
+
+func TestInstall_CacheReuse(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": nativeBinary(t)})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	cacheDir := t.TempDir()
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+
+	var mu sync.Mutex
+	var reports []Progress
+	opts := Options{
+		Repo:     "XTLS/Xray-core",
+		Version:  "v1.2.3",
+		APIBase:  s.srv.URL,
+		CacheDir: cacheDir,
+		Progress: func(p Progress) {
+			mu.Lock()
+			reports = append(reports, p)
+			mu.Unlock()
+		},
+	}
+	opts.DestDir = t.TempDir()
+	if _, _, err := Install(context.Background(), opts); err != nil {
+		t.Fatalf("first Install() error = %v", err)
+	}
+	cached := cachePath(cacheDir, "XTLS/Xray-core", "v1.2.3", assetName)
+	if got, err := os.ReadFile(cached); err != nil || !bytes.Equal(got, archive) {
+		t.Fatalf("cached archive missing or different: %v", err)
+	}
+	if _, err := os.Stat(cached + ".part"); !os.IsNotExist(err) {
+		t.Fatalf("partial file left behind: %v", err)
+	}
+
+	s.mu.Lock()
+	delete(s.requests, "/download/"+assetName)
+	s.mu.Unlock()
+	reports = nil
+
+	// A second machine sharing the cache installs without downloading.
+	opts.DestDir = t.TempDir()
+	if _, _, err := Install(context.Background(), opts); err != nil {
+		t.Fatalf("second Install() error = %v", err)
+	}
+	if _, ok := s.header("/download/" + assetName); ok {
+		t.Fatal("cached archive was downloaded again")
+	}
+	if len(reports) != 1 || !reports[0].Cached {
+		t.Fatalf("progress reports = %+v, want one cached report", reports)
+	}
+}
+
+func TestInstall_CorruptCacheIsReplaced(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": nativeBinary(t)})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	cacheDir := t.TempDir()
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	cached := cachePath(cacheDir, "XTLS/Xray-core", "v1.2.3", assetName)
+	if err := os.MkdirAll(filepath.Dir(cached), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cached, []byte("tampered"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, _, err := Install(context.Background(), Options{
+		Repo:     "XTLS/Xray-core",
+		Version:  "v1.2.3",
+		DestDir:  t.TempDir(),
+		APIBase:  s.srv.URL,
+		CacheDir: cacheDir,
+	})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if got, err := os.ReadFile(cached); err != nil || !bytes.Equal(got, archive) {
+		t.Fatalf("cached archive not replaced: %v", err)
+	}
+}
+
+func TestInstall_CacheSkipVerify(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": nativeBinary(t)})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	cacheDir := t.TempDir()
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+
+	_, _, err := Install(context.Background(), Options{
+		Repo:       "XTLS/Xray-core",
+		Version:    "v1.2.3",
+		DestDir:    t.TempDir(),
+		APIBase:    s.srv.URL,
+		CacheDir:   cacheDir,
+		SkipVerify: true,
+	})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	cached := cachePath(cacheDir, "XTLS/Xray-core", "v1.2.3", assetName)
+	for _, path := range []string{cached, cached + ".part"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Fatalf("unverified archive left in the cache at %s: %v", path, err)
+		}
+	}
+}
+
+func TestCachePath_StaysInsideCacheDir(t *testing.T) {
+	got := cachePath("/cache", "../../etc", "..", "a/../../b.zip")
+	if filepath.Dir(filepath.Dir(filepath.Dir(got))) != "/cache" {
+		t.Fatalf("cachePath() = %q escapes the cache dir", got)
+	}
+}
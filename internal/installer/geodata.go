@@ -0,0 +1,222 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultGeodataRepo publishes daily geoip.dat/geosite.dat builds together
+// with .sha256sum files.
+const DefaultGeodataRepo = "Loyalsoldier/v2ray-rules-dat"
+
+// GeodataFiles are the rule files Xray and V2Ray look up next to their binary
+// for geoip:/geosite: matchers.
+var GeodataFiles = []string{"geoip.dat", "geosite.dat"}
+
+// InstallGeodata downloads files (GeodataFiles when empty) from a rule-set
+// release into opts.DestDir. Every file is verified against its checksum
+// sibling unless opts.SkipVerify is set; nothing is written unless all of
+// them download and verify.
+func InstallGeodata(ctx context.Context, opts Options, files []string) (installed []string, tag string, err error) {
+	repo := strings.TrimSpace(opts.Repo)
+	if repo == "" {
+		repo = DefaultGeodataRepo
+	}
+	version := strings.TrimSpace(opts.Version)
+	if version == "" {
+		version = "latest"
+	}
+	destDir := strings.TrimSpace(opts.DestDir)
+	if destDir == "" {
+		destDir = "."
+	}
+	if len(files) == 0 {
+		files = GeodataFiles
+	}
+	if !opts.Force {
+		if err := checkNotExist(destDir, files); err != nil {
+			return nil, "", err
+		}
+	}
+
+	gh := newRemote(opts)
+	rel, err := gh.fetchRelease(ctx, repo, version)
+	if err != nil {
+		return nil, "", err
+	}
+	if rel.TagName == "" {
+		rel.TagName = version
+	}
+
+	selected := make([]asset, 0, len(files))
+	for _, name := range files {
+		a, ok := findAsset(rel.Assets, name)
+		if !ok {
+			return nil, rel.TagName, fmt.Errorf("release %s has no %s asset", rel.TagName, name)
+		}
+		selected = append(selected, a)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "proxy-node-geodata-")
+	if err != nil {
+		return nil, rel.TagName, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	downloaded := make([]string, 0, len(selected))
+	for _, a := range selected {
+		path := filepath.Join(tmpDir, filepath.Base(a.Name))
+		if err := gh.downloadFile(ctx, a.URL, path); err != nil {
+			return nil, rel.TagName, err
+		}
+		if !opts.SkipVerify {
+			if err := gh.verifyAsset(ctx, rel.Assets, a, path); err != nil {
+				return nil, rel.TagName, err
+			}
+		}
+		downloaded = append(downloaded, path)
+	}
+
+	if err := os.MkdirAll(destDir, 0o755); err != nil {
+		return nil, rel.TagName, fmt.Errorf("create destination dir: %w", err)
+	}
+	for _, src := range downloaded {
+		dst := filepath.Join(destDir, filepath.Base(src))
+		if err := copyFile(src, dst, 0o644); err != nil {
+			return installed, rel.TagName, err
+		}
+		installed = append(installed, dst)
+	}
+	return installed, rel.TagName, nil
+}
+
+func findAsset(assets []asset, name string) (asset, bool) {
+	for _, a := range assets {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+	return asset{}, false
+}
+
+func checkNotExist(dir string, names []string) error {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("destination already exists: %s (use --force to overwrite)", path)
+		}
+	}
+	return nil
+}
+
+// installBundledGeodata copies the .dat files shipped inside a core archive
+// into destDir.
+func installBundledGeodata(archivePath, workDir, destDir string, force bool) error {
+	extracted, err := extractGeodata(archivePath, workDir)
+	if err != nil {
+		return err
+	}
+	if len(extracted) == 0 {
+		return errors.New("archive has no geoip.dat/geosite.dat (use install-geodata instead)")
+	}
+	for _, src := range extracted {
+		dst := filepath.Join(destDir, filepath.Base(src))
+		if !force {
+			if _, err := os.Stat(dst); err == nil {
+				return fmt.Errorf("destination already exists: %s (use --force to overwrite)", dst)
+			}
+		}
+		if err := copyFile(src, dst, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isGeodataName(base string) bool {
+	base = strings.ToLower(base)
+	return strings.HasSuffix(base, ".dat") && (strings.Contains(base, "geoip") || strings.Contains(base, "geosite"))
+}
+
+// extractGeodata writes every geoip/geosite .dat member of the archive into
+// workDir and returns their paths.
+func extractGeodata(archivePath, workDir string) ([]string, error) {
+	lower := strings.ToLower(archivePath)
+	switch {
+	case strings.HasSuffix(lower, ".zip"):
+		return extractGeodataFromZip(archivePath, workDir)
+	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
+		return extractGeodataFromTarGz(archivePath, workDir)
+	}
+	return nil, fmt.Errorf("unsupported archive format: %s", archivePath)
+}
+
+func extractGeodataFromZip(path, workDir string) ([]string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("open zip: %w", err)
+	}
+	defer r.Close()
+
+	var out []string
+	for _, f := range r.File {
+		if err := checkMember(f.Name, f.Mode(), int64(f.UncompressedSize64)); err != nil {
+			return nil, err
+		}
+		if f.FileInfo().IsDir() || !isGeodataName(filepath.Base(f.Name)) {
+			continue
+		}
+		dst := filepath.Join(workDir, filepath.Base(f.Name))
+		if err := extractZipFile(f, dst); err != nil {
+			return nil, err
+		}
+		out = append(out, dst)
+	}
+	return out, nil
+}
+
+func extractGeodataFromTarGz(path, workDir string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open tar.gz: %w", err)
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	var out []string
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read tar member: %w", err)
+		}
+		if err := checkTarMember(h); err != nil {
+			return nil, err
+		}
+		if h.FileInfo().IsDir() || !isGeodataName(filepath.Base(h.Name)) {
+			continue
+		}
+		dst := filepath.Join(workDir, filepath.Base(h.Name))
+		if err := writeTarMember(tr, dst); err != nil {
+			return nil, err
+		}
+		out = append(out, dst)
+	}
+	return out, nil
+}
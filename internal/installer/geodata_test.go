@@ -0,0 +1,128 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ruleSetRelease serves a Loyalsoldier-style release: plain .dat assets with
+// "<hex>  <name>" .sha256sum siblings.
+func ruleSetRelease(t *testing.T, files map[string]string, sums map[string]string) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/"+DefaultGeodataRepo+"/releases/latest" {
+			rel := release{TagName: "202610152210"}
+			for name := range files {
+				rel.Assets = append(rel.Assets, asset{Name: name, URL: srv.URL + "/dl/" + name})
+			}
+			for name := range sums {
+				rel.Assets = append(rel.Assets, asset{Name: name + ".sha256sum", URL: srv.URL + "/dl/" + name + ".sha256sum"})
+			}
+			_ = json.NewEncoder(w).Encode(rel)
+			return
+		}
+		name := strings.TrimPrefix(r.URL.Path, "/dl/")
+		if base, ok := strings.CutSuffix(name, ".sha256sum"); ok {
+			_, _ = w.Write([]byte(sums[base] + "  " + base + "\n"))
+			return
+		}
+		body, ok := files[name]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func sha256Hex(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestInstallGeodata(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{"geoip.dat": "ip rules", "geosite.dat": "site rules"}
+	srv := ruleSetRelease(t, files, map[string]string{"geoip.dat": sha256Hex("ip rules"), "geosite.dat": sha256Hex("site rules")})
+	dest := t.TempDir()
+
+	paths, tag, err := InstallGeodata(context.Background(), Options{DestDir: dest, APIBase: srv.URL}, nil)
+	if err != nil {
+		t.Fatalf("InstallGeodata() error = %v", err)
+	}
+	if tag != "202610152210" || len(paths) != 2 {
+		t.Fatalf("InstallGeodata() = %v, %q", paths, tag)
+	}
+	for name, want := range files {
+		got, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v", name, got, err)
+		}
+	}
+
+	if _, _, err := InstallGeodata(context.Background(), Options{DestDir: dest, APIBase: srv.URL}, nil); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("second InstallGeodata() error = %v, want exists error", err)
+	}
+}
+
+func TestInstallGeodata_MismatchWritesNothing(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{"geoip.dat": "ip rules", "geosite.dat": "site rules"}
+	srv := ruleSetRelease(t, files, map[string]string{"geoip.dat": sha256Hex("ip rules"), "geosite.dat": sha256Hex("other")})
+	dest := t.TempDir()
+
+	_, _, err := InstallGeodata(context.Background(), Options{DestDir: dest, APIBase: srv.URL}, nil)
+	var mismatch *ChecksumError
+	if !errors.As(err, &mismatch) || mismatch.Asset != "geosite.dat" {
+		t.Fatalf("InstallGeodata() error = %v, want geosite.dat mismatch", err)
+	}
+	entries, _ := os.ReadDir(dest)
+	if len(entries) != 0 {
+		t.Fatalf("dest has %d entries, want none", len(entries))
+	}
+}
+
+func TestInstallGeodata_MissingAsset(t *testing.T) {
+	t.Parallel()
+
+	srv := ruleSetRelease(t, map[string]string{"geoip.dat": "ip"}, nil)
+	_, _, err := InstallGeodata(context.Background(), Options{DestDir: t.TempDir(), APIBase: srv.URL, SkipVerify: true}, nil)
+	if err == nil || !strings.Contains(err.Error(), "geosite.dat") {
+		t.Fatalf("InstallGeodata() error = %v, want missing geosite.dat", err)
+	}
+}
+
+func TestInstall_BundledGeodata(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": nativeBinary(t), "geoip.dat": "ip", "geosite.dat": "site", "README.md": "doc"})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	dest := t.TempDir()
+
+	if _, _, err := Install(context.Background(), Options{Repo: "XTLS/Xray-core", DestDir: dest, APIBase: s.srv.URL, Geodata: true}); err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	for name, want := range map[string]string{"geoip.dat": "ip", "geosite.dat": "site"} {
+		got, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s = %q, %v", name, got, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dest, "README.md")); !os.IsNotExist(err) {
+		t.Fatalf("README.md installed: %v", err)
+	}
+}
@@ -10,22 +10,56 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 )
 
+// DefaultAPIBase is the GitHub REST API root used when Options.APIBase is
+// empty.
+const DefaultAPIBase = "https://api.github.com"
+
 type Options struct {
 	Repo    string
 	Version string
 	DestDir string
 	Force   bool
+	// APIBase replaces DefaultAPIBase, e.g. for GitHub Enterprise or a local
+	// stand-in server.
+	APIBase string
+	// Mirror is prepended to every asset download URL
+	// (https://mirror.example/https://github.com/...). Release metadata is
+	// still fetched from APIBase.
+	Mirror string
+	// Token is sent as a bearer token to the API only, never to the mirror.
+	Token string
+	// Geodata also extracts the geoip.dat/geosite.dat files bundled in the
+	// archive next to the binary.
+	Geodata bool
+	// Asset is a glob over release asset names (Xray-linux-arm32-v6.zip,
+	// *mips32le*) that overrides the automatic platform match.
+	Asset string
+	// SkipVerify installs without checking the archive against the release's
+	// .dgst checksum file.
+	SkipVerify bool
+	// CacheDir keeps downloaded archives between installs (see
+	// DefaultCacheDir). Empty downloads into a temp dir every time.
+	CacheDir string
+	// Progress, when set, is called while assets download.
+	Progress func(Progress)
+	// HTTPClient carries every API and download request, e.g. through a
+	// local SOCKS proxy. Nil uses http.DefaultClient.
+	HTTPClient *http.Client
 }
 
 type release struct {
-	TagName string  `json:"tag_name"`
-	Assets  []asset `json:"assets"`
+	TagName     string    `json:"tag_name"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+	Assets      []asset   `json:"assets"`
 }
 
 type asset struct {
@@ -47,7 +81,8 @@ func Install(ctx context.Context, opts Options) (installedPath, tag string, err
 		destDir = "."
 	}
 
-	rel, err := fetchRelease(ctx, repo, version)
+	gh := newRemote(opts)
+	rel, err := gh.fetchRelease(ctx, repo, version)
 	if err != nil {
 		return "", "", err
 	}
@@ -55,7 +90,7 @@ func Install(ctx context.Context, opts Options) (installedPath, tag string, err
 		rel.TagName = version
 	}
 
-	selected, err := chooseAsset(rel.Assets, runtime.GOOS, runtime.GOARCH)
+	selected, err := chooseAsset(rel.Assets, hostPlatform(), opts.Asset)
 	if err != nil {
 		return "", rel.TagName, err
 	}
@@ -66,49 +101,139 @@ func Install(ctx context.Context, opts Options) (installedPath, tag string, err
 	}
 	defer os.RemoveAll(tmpDir)
 
-	downloadPath := filepath.Join(tmpDir, selected.Name)
-	if err := downloadFile(ctx, selected.URL, downloadPath); err != nil {
+	var cached string
+	if opts.CacheDir != "" {
+		cached = cachePath(opts.CacheDir, repo, rel.TagName, selected.Name)
+	}
+	downloadPath, err := gh.fetchAsset(ctx, rel.Assets, selected, cached, filepath.Join(tmpDir, selected.Name), opts.SkipVerify)
+	if err != nil {
 		return "", rel.TagName, err
 	}
 
-	binName := expectedBinaryName(repo)
+	destPath, err := installArchive(downloadPath, tmpDir, destDir, expectedBinaryName(repo), opts)
+	return destPath, rel.TagName, err
+}
+
+// InstallFromFile installs the core from a local .zip/.tar.gz release archive
+// without any network access. The binary name is taken from the archive
+// contents; opts.Repo, Version and the remote settings are ignored.
+func InstallFromFile(archivePath string, opts Options) (installedPath string, err error) {
+	if _, err := os.Stat(archivePath); err != nil {
+		return "", fmt.Errorf("open archive: %w", err)
+	}
+	destDir := strings.TrimSpace(opts.DestDir)
+	if destDir == "" {
+		destDir = "."
+	}
+
+	tmpDir, err := os.MkdirTemp("", "proxy-node-core-")
+	if err != nil {
+		return "", fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	return installArchive(archivePath, tmpDir, destDir, "", opts)
+}
+
+// installArchive extracts the core binary from archivePath into destDir,
+// plus the bundled geodata when opts.Geodata is set. An empty binName keeps
+// the name of the binary found in the archive.
+func installArchive(archivePath, workDir, destDir, binName string, opts Options) (string, error) {
+	extractedPath, err := extractBinary(archivePath, workDir, binName)
+	if err != nil {
+		return "", err
+	}
+	if err := checkExecutable(extractedPath, runtime.GOOS, runtime.GOARCH); err != nil {
+		return "", err
+	}
+	if binName == "" {
+		binName = filepath.Base(extractedPath)
+	}
+
 	destPath := filepath.Join(destDir, binName)
 	if !opts.Force {
 		if _, statErr := os.Stat(destPath); statErr == nil {
-			return "", rel.TagName, fmt.Errorf("destination already exists: %s (use --force to overwrite)", destPath)
+			return "", fmt.Errorf("destination already exists: %s (use --force to overwrite)", destPath)
+		}
+		if opts.Geodata {
+			if err := checkNotExist(destDir, GeodataFiles); err != nil {
+				return "", err
+			}
 		}
 	}
 	if err := os.MkdirAll(destDir, 0o755); err != nil {
-		return "", rel.TagName, fmt.Errorf("create destination dir: %w", err)
+		return "", fmt.Errorf("create destination dir: %w", err)
 	}
 
-	extractedPath, err := extractBinary(downloadPath, tmpDir, binName)
-	if err != nil {
-		return "", rel.TagName, err
+	if err := copyExecutable(extractedPath, destPath); err != nil {
+		return "", err
+	}
+	if opts.Geodata {
+		if err := installBundledGeodata(archivePath, workDir, destDir, opts.Force); err != nil {
+			return destPath, err
+		}
 	}
+	return destPath, nil
+}
 
-	if err := copyExecutable(extractedPath, destPath); err != nil {
-		return "", rel.TagName, err
+// remote is where release metadata and assets come from.
+type remote struct {
+	apiBase  string
+	mirror   string
+	token    string
+	client   *http.Client
+	progress func(Progress)
+}
+
+func newRemote(opts Options) remote {
+	apiBase := strings.TrimRight(strings.TrimSpace(opts.APIBase), "/")
+	if apiBase == "" {
+		apiBase = DefaultAPIBase
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return remote{
+		apiBase:  apiBase,
+		mirror:   strings.TrimSpace(opts.Mirror),
+		token:    strings.TrimSpace(opts.Token),
+		client:   client,
+		progress: opts.Progress,
+	}
+}
+
+// assetURL applies the mirror prefix to a browser_download_url.
+func (r remote) assetURL(raw string) string {
+	if r.mirror == "" {
+		return raw
 	}
-	return destPath, rel.TagName, nil
+	return r.mirror + raw
 }
 
-func fetchRelease(ctx context.Context, repo, version string) (*release, error) {
+func (r remote) apiHeaders(req *http.Request) {
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "proxy-node")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+}
+
+func (r remote) fetchRelease(ctx context.Context, repo, version string) (*release, error) {
 	var apiURL string
 	if strings.EqualFold(version, "latest") {
-		apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/latest", repo)
+		apiURL = fmt.Sprintf("%s/repos/%s/releases/latest", r.apiBase, repo)
 	} else {
-		apiURL = fmt.Sprintf("https://api.github.com/repos/%s/releases/tags/%s", repo, version)
+		apiURL = fmt.Sprintf("%s/repos/%s/releases/tags/%s", r.apiBase, repo, url.PathEscape(version))
 	}
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("build release request: %w", err)
 	}
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("User-Agent", "proxy-node")
+	r.apiHeaders(req)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := r.client.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("fetch release metadata: %w", err)
 	}
@@ -128,41 +253,6 @@ func fetchRelease(ctx context.Context, repo, version string) (*release, error) {
 	return &rel, nil
 }
 
-func chooseAsset(assets []asset, goos, goarch string) (asset, error) {
-	osTokens := tokensForOS(goos)
-	archTokens := tokensForArch(goarch)
-
-	var candidates []asset
-	for _, a := range assets {
-		name := strings.ToLower(a.Name)
-		if !isArchive(name) {
-			continue
-		}
-		if !containsAny(name, osTokens) {
-			continue
-		}
-		if !containsAny(name, archTokens) {
-			continue
-		}
-		candidates = append(candidates, a)
-	}
-
-	if len(candidates) == 0 {
-		var names []string
-		for _, a := range assets {
-			names = append(names, a.Name)
-		}
-		return asset{}, fmt.Errorf("no matching release asset for %s/%s, assets: %s", goos, goarch, strings.Join(names, ", "))
-	}
-
-	for _, c := range candidates {
-		if strings.HasSuffix(strings.ToLower(c.Name), ".zip") {
-			return c, nil
-		}
-	}
-	return candidates[0], nil
-}
-
 func expectedBinaryName(repo string) string {
 	v := strings.ToLower(repo)
 	if strings.Contains(v, "v2ray") {
@@ -191,6 +281,9 @@ func extractFromZip(path, workDir, preferredName string) (string, error) {
 
 	var fallback string
 	for _, f := range r.File {
+		if err := checkMember(f.Name, f.Mode(), int64(f.UncompressedSize64)); err != nil {
+			return "", err
+		}
 		if f.FileInfo().IsDir() {
 			continue
 		}
@@ -232,8 +325,8 @@ func extractZipFile(f *zip.File, dst string) error {
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, rc); err != nil {
-		return fmt.Errorf("extract zip member: %w", err)
+	if err := copyLimited(out, rc); err != nil {
+		return fmt.Errorf("extract zip member %s: %w", f.Name, err)
 	}
 	return nil
 }
@@ -261,6 +354,9 @@ func extractFromTarGz(path, workDir, preferredName string) (string, error) {
 		if err != nil {
 			return "", fmt.Errorf("read tar member: %w", err)
 		}
+		if err := checkTarMember(h); err != nil {
+			return "", err
+		}
 		if h.FileInfo().IsDir() {
 			continue
 		}
@@ -293,75 +389,72 @@ func writeTarMember(r io.Reader, dst string) error {
 	}
 	defer out.Close()
 
-	if _, err := io.Copy(out, r); err != nil {
+	if err := copyLimited(out, r); err != nil {
 		return fmt.Errorf("extract tar member: %w", err)
 	}
 	return nil
 }
 
+// copyExecutable installs src at dst, keeping the binary it replaces as
+// dst+BackupSuffix for Rollback.
 func copyExecutable(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		if err := linkOrCopy(dst, dst+BackupSuffix); err != nil {
+			return fmt.Errorf("back up previous binary: %w", err)
+		}
+	}
+	return copyFile(src, dst, 0o755)
+}
+
+// copyFile writes src to a temp file next to dst and renames it into place,
+// so dst is never seen half-written.
+func copyFile(src, dst string, perm os.FileMode) error {
 	in, err := os.Open(src)
 	if err != nil {
-		return fmt.Errorf("open extracted binary: %w", err)
+		return fmt.Errorf("open extracted file: %w", err)
 	}
 	defer in.Close()
 
-	out, err := os.Create(dst)
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-")
 	if err != nil {
-		return fmt.Errorf("create destination binary: %w", err)
+		return fmt.Errorf("create destination file: %w", err)
 	}
+	tmp := out.Name()
+	defer os.Remove(tmp)
+
 	if _, err := io.Copy(out, in); err != nil {
 		out.Close()
-		return fmt.Errorf("copy binary: %w", err)
+		return fmt.Errorf("copy %s: %w", filepath.Base(dst), err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("sync destination file: %w", err)
 	}
 	if err := out.Close(); err != nil {
-		return fmt.Errorf("close destination binary: %w", err)
+		return fmt.Errorf("close destination file: %w", err)
 	}
-	if err := os.Chmod(dst, 0o755); err != nil {
-		return fmt.Errorf("chmod destination binary: %w", err)
+	if err := os.Chmod(tmp, perm); err != nil {
+		return fmt.Errorf("chmod destination file: %w", err)
 	}
-	return nil
-}
-
-func tokensForOS(goos string) []string {
-	switch goos {
-	case "linux":
-		return []string{"linux"}
-	case "darwin":
-		return []string{"darwin", "macos", "osx"}
-	case "windows":
-		return []string{"windows", "win"}
-	default:
-		return []string{goos}
+	if err := os.Rename(tmp, dst); err != nil {
+		return fmt.Errorf("move %s into place: %w", filepath.Base(dst), err)
 	}
+	return nil
 }
 
-func tokensForArch(goarch string) []string {
-	switch goarch {
-	case "amd64":
-		return []string{"amd64", "x86_64", "64"}
-	case "386":
-		return []string{"386", "i386", "32"}
-	case "arm64":
-		return []string{"arm64", "aarch64"}
-	case "arm":
-		return []string{"armv7", "armv6", "arm"}
-	default:
-		return []string{goarch}
+// linkOrCopy atomically replaces dst with the contents of src, hard-linking
+// when the filesystem allows it.
+func linkOrCopy(src, dst string) error {
+	tmp := dst + ".tmp"
+	_ = os.Remove(tmp)
+	if err := os.Link(src, tmp); err == nil {
+		return os.Rename(tmp, dst)
 	}
-}
-
-func isArchive(name string) bool {
-	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
-}
-
-func containsAny(v string, tokens []string) bool {
-	for _, t := range tokens {
-		if strings.Contains(v, t) {
-			return true
-		}
+	st, err := os.Stat(src)
+	if err != nil {
+		return err
 	}
-	return false
+	return copyFile(src, dst, st.Mode().Perm())
 }
 
 func isLikelyCoreBinary(base, preferredName string) bool {
@@ -386,32 +479,3 @@ func isExecutableName(base string) bool {
 	}
 	return true
 }
-
-func downloadFile(ctx context.Context, url, dst string) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return fmt.Errorf("build download request: %w", err)
-	}
-	req.Header.Set("User-Agent", "proxy-node")
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("download asset: %w", err)
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode >= 300 {
-		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-		return fmt.Errorf("download failed with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
-	}
-
-	out, err := os.Create(dst)
-	if err != nil {
-		return fmt.Errorf("create download file: %w", err)
-	}
-	defer out.Close()
-
-	if _, err := io.Copy(out, resp.Body); err != nil {
-		return fmt.Errorf("write download file: %w", err)
-	}
-	return nil
-}
@@ -0,0 +1,363 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func zipArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func tarGzArchive(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, body := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+var (
+	nativeOnce sync.Once
+	nativeBody string
+	nativeErr  error
+)
+
+// nativeBinary returns the bytes of the running test binary: a real
+// executable for this GOOS/GOARCH that passes checkExecutable.
+func nativeBinary(t *testing.T) string {
+	t.Helper()
+	nativeOnce.Do(func() {
+		var path string
+		if path, nativeErr = os.Executable(); nativeErr == nil {
+			var b []byte
+			b, nativeErr = os.ReadFile(path)
+			nativeBody = string(b)
+		}
+	})
+	if nativeErr != nil {
+		t.Fatalf("read test binary: %v", nativeErr)
+	}
+	return nativeBody
+}
+
+// standIn is a local replacement for the GitHub API and its download host.
+type standIn struct {
+	srv      *httptest.Server
+	mu       sync.Mutex
+	requests map[string]http.Header
+}
+
+// newStandIn serves one release of repo whose asset URLs live under
+// assetBase (the server's own URL when empty).
+func newStandIn(t *testing.T, repo, tag string, archive []byte, assetBase string) *standIn {
+	t.Helper()
+	s := &standIn{requests: make(map[string]http.Header)}
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	s.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path] = r.Header.Clone()
+		s.mu.Unlock()
+
+		base := assetBase
+		if base == "" {
+			base = s.srv.URL
+		}
+		switch {
+		case r.URL.Path == "/repos/"+repo+"/releases/tags/"+tag || r.URL.Path == "/repos/"+repo+"/releases/latest":
+			_ = json.NewEncoder(w).Encode(release{TagName: tag, Assets: []asset{
+				{Name: assetName, URL: base + "/download/" + assetName},
+				{Name: assetName + ".dgst", URL: base + "/download/" + assetName + ".dgst"},
+			}})
+		case strings.HasSuffix(r.URL.Path, "/download/"+assetName):
+			_, _ = w.Write(archive)
+		case strings.HasSuffix(r.URL.Path, "/download/"+assetName+".dgst"):
+			_, _ = w.Write([]byte(xrayDigest(string(archive))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(s.srv.Close)
+	return s
+}
+
+func (s *standIn) header(path string) (http.Header, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, ok := s.requests[path]
+	return h, ok
+}
+
+func TestInstall_StandInServer(t *testing.T) {
+	t.Parallel()
+
+	bin := nativeBinary(t)
+	archive := zipArchive(t, map[string]string{"xray": bin, "geoip.dat": "geo"})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	dest := t.TempDir()
+
+	path, tag, err := Install(context.Background(), Options{
+		Repo:    "XTLS/Xray-core",
+		Version: "v1.2.3",
+		DestDir: dest,
+		APIBase: s.srv.URL + "/",
+		Token:   "secret-token",
+	})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	if tag != "v1.2.3" || path != filepath.Join(dest, "xray") {
+		t.Fatalf("Install() = %q, %q", path, tag)
+	}
+	body, err := os.ReadFile(path)
+	if err != nil || string(body) != bin {
+		t.Fatalf("installed binary differs from the archive member: %v", err)
+	}
+
+	h, ok := s.header("/repos/XTLS/Xray-core/releases/tags/v1.2.3")
+	if !ok {
+		t.Fatal("release metadata was not requested from the API base")
+	}
+	if got := h.Get("Authorization"); got != "Bearer secret-token" {
+		t.Fatalf("API Authorization = %q", got)
+	}
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	h, ok = s.header("/download/" + assetName)
+	if !ok {
+		t.Fatal("asset was not downloaded")
+	}
+	if got := h.Get("Authorization"); got != "" {
+		t.Fatalf("download Authorization = %q, want none", got)
+	}
+}
+
+func TestInstall_Mirror(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"Xray-core/xray": nativeBinary(t)})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "https://github.invalid")
+
+	_, _, err := Install(context.Background(), Options{
+		Repo:    "XTLS/Xray-core",
+		DestDir: t.TempDir(),
+		APIBase: s.srv.URL,
+		Mirror:  s.srv.URL + "/",
+		Token:   "secret-token",
+	})
+	// github.invalid does not resolve, so the checksum could only come from
+	// the mirror, which must not be trusted with it.
+	if err == nil || !strings.Contains(err.Error(), "--skip-verify") {
+		t.Fatalf("Install() error = %v, want a checksum failure pointing at --skip-verify", err)
+	}
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	h, ok := s.header("/https://github.invalid/download/" + assetName)
+	if !ok {
+		t.Fatal("asset was not downloaded through the mirror")
+	}
+	if got := h.Get("Authorization"); got != "" {
+		t.Fatalf("mirror Authorization = %q, want none", got)
+	}
+	if _, ok := s.header("/https://github.invalid/download/" + assetName + ".dgst"); ok {
+		t.Fatal("checksum was downloaded through the mirror")
+	}
+}
+
+func TestInstall_MirrorCannotForgeChecksum(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": nativeBinary(t)})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	forged := zipArchive(t, map[string]string{"xray": "evil"})
+	var dgstFromMirror bool
+	mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ".zip"):
+			_, _ = w.Write(forged)
+		case strings.HasSuffix(r.URL.Path, ".dgst"):
+			dgstFromMirror = true
+			_, _ = w.Write([]byte(xrayDigest(string(forged))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer mirror.Close()
+
+	_, _, err := Install(context.Background(), Options{
+		Repo:    "XTLS/Xray-core",
+		DestDir: t.TempDir(),
+		APIBase: s.srv.URL,
+		Mirror:  mirror.URL + "/",
+	})
+	var mismatch *ChecksumError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Install() error = %v, want *ChecksumError", err)
+	}
+	if dgstFromMirror {
+		t.Fatal("checksum was fetched from the mirror although the release host was reachable")
+	}
+}
+
+func TestInstall_ChecksumMismatchLeavesNoBinary(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": "bin"})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	// Serve a different archive than the one the .dgst describes.
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".zip") {
+			_, _ = w.Write(zipArchive(t, map[string]string{"xray": "evil"}))
+			return
+		}
+		s.srv.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer bad.Close()
+
+	dest := t.TempDir()
+	_, _, err := Install(context.Background(), Options{
+		Repo:    "XTLS/Xray-core",
+		DestDir: dest,
+		APIBase: s.srv.URL,
+		Mirror:  bad.URL + "/",
+	})
+	var mismatch *ChecksumError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Install() error = %v, want *ChecksumError", err)
+	}
+	if _, statErr := os.Stat(filepath.Join(dest, "xray")); !os.IsNotExist(statErr) {
+		t.Fatalf("binary installed despite mismatch: %v", statErr)
+	}
+}
+
+func TestInstallFromFile_InfersBinaryName(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "v2ray-linux-64.tar.gz")
+	bin := nativeBinary(t)
+	body := tarGzArchive(t, map[string]string{"v2ray": bin, "config.json": "{}"})
+	if err := os.WriteFile(archive, body, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dest := filepath.Join(dir, "core")
+
+	path, err := InstallFromFile(archive, Options{DestDir: dest})
+	if err != nil {
+		t.Fatalf("InstallFromFile() error = %v", err)
+	}
+	if path != filepath.Join(dest, "v2ray") {
+		t.Fatalf("InstallFromFile() = %q, want v2ray in dest", path)
+	}
+	if body, err := os.ReadFile(path); err != nil || string(body) != bin {
+		t.Fatalf("installed binary differs from the archive member: %v", err)
+	}
+
+	if _, err := InstallFromFile(archive, Options{DestDir: dest}); err == nil || !strings.Contains(err.Error(), "--force") {
+		t.Fatalf("second InstallFromFile() error = %v, want exists error", err)
+	}
+}
+
+func TestInstallFromFile_Zip(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "Xray-linux-64.zip")
+	if err := os.WriteFile(archive, zipArchive(t, map[string]string{"Xray-linux-64/xray": nativeBinary(t), "geosite.dat": "site"}), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	path, err := InstallFromFile(archive, Options{DestDir: dir, Geodata: true})
+	if err != nil {
+		t.Fatalf("InstallFromFile() error = %v", err)
+	}
+	if filepath.Base(path) != "xray" {
+		t.Fatalf("InstallFromFile() = %q, want xray", path)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "geosite.dat")); err != nil {
+		t.Fatalf("geosite.dat not installed: %v", err)
+	}
+}
+
+func TestInstallFromFile_MissingArchive(t *testing.T) {
+	t.Parallel()
+
+	if _, err := InstallFromFile(filepath.Join(t.TempDir(), "nope.zip"), Options{}); err == nil {
+		t.Fatal("InstallFromFile() error = nil, want error")
+	}
+}
+
+type countingTransport struct {
+	mu    sync.Mutex
+	paths []string
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.mu.Lock()
+	c.paths = append(c.paths, req.URL.Path)
+	c.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestInstall_HTTPClientCarriesAllRequests(t *testing.T) {
+	t.Parallel()
+
+	archive := zipArchive(t, map[string]string{"xray": nativeBinary(t)})
+	s := newStandIn(t, "XTLS/Xray-core", "v1.2.3", archive, "")
+	via := &countingTransport{}
+
+	_, _, err := Install(context.Background(), Options{
+		Repo:       "XTLS/Xray-core",
+		DestDir:    t.TempDir(),
+		APIBase:    s.srv.URL,
+		HTTPClient: &http.Client{Transport: via},
+	})
+	if err != nil {
+		t.Fatalf("Install() error = %v", err)
+	}
+	assetName := fmt.Sprintf("Xray-%s-%s.zip", runtime.GOOS, runtime.GOARCH)
+	want := []string{"/repos/XTLS/Xray-core/releases/latest", "/download/" + assetName, "/download/" + assetName + ".dgst"}
+	if strings.Join(via.paths, " ") != strings.Join(want, " ") {
+		t.Fatalf("requests through client = %v, want %v", via.paths, want)
+	}
+}
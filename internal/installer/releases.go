@@ -0,0 +1,114 @@
+package installer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// BackupSuffix names the previous binary kept next to an installed core.
+const BackupSuffix = ".bak"
+
+// Release is one published release of a repo.
+type Release struct {
+	Tag         string    `json:"tag"`
+	Prerelease  bool      `json:"prerelease"`
+	PublishedAt time.Time `json:"published_at"`
+}
+
+// ListReleases returns up to limit releases of opts.Repo, newest first.
+func ListReleases(ctx context.Context, opts Options, limit int) ([]Release, error) {
+	repo := strings.TrimSpace(opts.Repo)
+	if repo == "" {
+		return nil, errors.New("repo is required")
+	}
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+	gh := newRemote(opts)
+	apiURL := fmt.Sprintf("%s/repos/%s/releases?per_page=%d", gh.apiBase, repo, limit)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build release list request: %w", err)
+	}
+	gh.apiHeaders(req)
+
+	resp, err := gh.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch release list: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return nil, fmt.Errorf("github API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var rels []release
+	if err := json.NewDecoder(resp.Body).Decode(&rels); err != nil {
+		return nil, fmt.Errorf("decode release list: %w", err)
+	}
+	out := make([]Release, 0, len(rels))
+	for _, r := range rels {
+		out = append(out, Release{Tag: r.TagName, Prerelease: r.Prerelease, PublishedAt: r.PublishedAt})
+	}
+	return out, nil
+}
+
+// ResolveTag returns the tag opts.Version ("latest" by default) refers to.
+func ResolveTag(ctx context.Context, opts Options) (string, error) {
+	version := strings.TrimSpace(opts.Version)
+	if version == "" {
+		version = "latest"
+	}
+	rel, err := newRemote(opts).fetchRelease(ctx, strings.TrimSpace(opts.Repo), version)
+	if err != nil {
+		return "", err
+	}
+	if rel.TagName == "" {
+		return version, nil
+	}
+	return rel.TagName, nil
+}
+
+// BinaryPath is where Install puts the core for opts.
+func BinaryPath(opts Options) string {
+	destDir := strings.TrimSpace(opts.DestDir)
+	if destDir == "" {
+		destDir = "."
+	}
+	return filepath.Join(destDir, expectedBinaryName(opts.Repo))
+}
+
+// Rollback restores the binary an install replaced (path+BackupSuffix). The
+// binary being replaced becomes the new backup, so a second Rollback undoes
+// the first. path is never missing or half-written along the way.
+func Rollback(path string) error {
+	bak := path + BackupSuffix
+	if _, err := os.Stat(bak); err != nil {
+		return fmt.Errorf("no previous binary to roll back to: %s", bak)
+	}
+	_, statErr := os.Stat(path)
+	current := statErr == nil
+	swap := path + ".rollback"
+	if current {
+		if err := linkOrCopy(path, swap); err != nil {
+			return fmt.Errorf("save current binary: %w", err)
+		}
+	}
+	if err := os.Rename(bak, path); err != nil {
+		return fmt.Errorf("restore previous binary: %w", err)
+	}
+	if current {
+		if err := os.Rename(swap, bak); err != nil {
+			return fmt.Errorf("keep replaced binary as backup: %w", err)
+		}
+	}
+	return nil
+}
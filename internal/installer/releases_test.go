@@ -0,0 +1,124 @@
+package installer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListReleases(t *testing.T) {
+	t.Parallel()
+
+	var gotQuery, gotAuth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/XTLS/Xray-core/releases" {
+			http.NotFound(w, r)
+			return
+		}
+		gotQuery, gotAuth = r.URL.RawQuery, r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`[
+			{"tag_name": "v26.2.6", "prerelease": false, "published_at": "2026-02-06T10:00:00Z"},
+			{"tag_name": "v26.2.0-beta", "prerelease": true, "published_at": "2026-01-30T10:00:00Z"}
+		]`))
+	}))
+	defer srv.Close()
+
+	releases, err := ListReleases(context.Background(), Options{Repo: "XTLS/Xray-core", APIBase: srv.URL, Token: "tok"}, 5)
+	if err != nil {
+		t.Fatalf("ListReleases() error = %v", err)
+	}
+	if len(releases) != 2 || releases[0].Tag != "v26.2.6" || !releases[1].Prerelease {
+		t.Fatalf("ListReleases() = %+v", releases)
+	}
+	if releases[0].PublishedAt.Format("2006-01-02") != "2026-02-06" {
+		t.Fatalf("PublishedAt = %v", releases[0].PublishedAt)
+	}
+	if gotQuery != "per_page=5" || gotAuth != "Bearer tok" {
+		t.Fatalf("query = %q, auth = %q", gotQuery, gotAuth)
+	}
+}
+
+func TestCopyExecutable_KeepsBackupAndLeavesNoTemp(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "xray")
+	for _, body := range []string{"v1", "v2"} {
+		src := filepath.Join(t.TempDir(), "xray")
+		if err := os.WriteFile(src, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := copyExecutable(src, dst); err != nil {
+			t.Fatalf("copyExecutable(%s) error = %v", body, err)
+		}
+	}
+
+	assertFile(t, dst, "v2")
+	assertFile(t, dst+BackupSuffix, "v1")
+	st, err := os.Stat(dst)
+	if err != nil || st.Mode().Perm() != 0o755 {
+		t.Fatalf("installed mode = %v, %v", st.Mode(), err)
+	}
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp") {
+			t.Fatalf("leftover temp file %s", e.Name())
+		}
+	}
+}
+
+func TestRollback_SwapsWithBackup(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "xray")
+	if err := os.WriteFile(path, []byte("new"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path+BackupSuffix, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Rollback(path); err != nil {
+		t.Fatalf("Rollback() error = %v", err)
+	}
+	assertFile(t, path, "old")
+	assertFile(t, path+BackupSuffix, "new")
+
+	if err := Rollback(path); err != nil {
+		t.Fatalf("second Rollback() error = %v", err)
+	}
+	assertFile(t, path, "new")
+	assertFile(t, path+BackupSuffix, "old")
+}
+
+func TestRollback_NoBackup(t *testing.T) {
+	t.Parallel()
+
+	if err := Rollback(filepath.Join(t.TempDir(), "xray")); err == nil || !strings.Contains(err.Error(), "no previous binary") {
+		t.Fatalf("Rollback() error = %v, want no backup error", err)
+	}
+}
+
+func TestBinaryPath(t *testing.T) {
+	t.Parallel()
+
+	if got := BinaryPath(Options{Repo: "v2fly/v2ray-core", DestDir: "core"}); got != filepath.Join("core", "v2ray") {
+		t.Fatalf("BinaryPath(v2fly) = %q", got)
+	}
+	if got := BinaryPath(Options{Repo: "XTLS/Xray-core"}); got != "xray" {
+		t.Fatalf("BinaryPath(xray) = %q", got)
+	}
+}
+
+func assertFile(t *testing.T, path, want string) {
+	t.Helper()
+	got, err := os.ReadFile(path)
+	if err != nil || string(got) != want {
+		t.Fatalf("%s = %q, %v; want %q", filepath.Base(path), got, err, want)
+	}
+}
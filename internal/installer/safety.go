@@ -0,0 +1,183 @@
+package installer
+
+import (
+	"archive/tar"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// maxMemberBytes caps a single extracted file. Xray binaries are ~30 MB and
+// geosite.dat ~10 MB, so anything near this is not a release archive.
+const maxMemberBytes = 256 << 20
+
+// checkMember rejects archive members that are not plain files or
+// directories, or whose name would land outside the extraction directory.
+// Extraction only ever uses the base name, but an archive carrying such
+// members is not a genuine release and is refused as a whole.
+func checkMember(name string, mode os.FileMode, size int64) error {
+	if mode&os.ModeSymlink != 0 {
+		return fmt.Errorf("archive member %q is a symlink", name)
+	}
+	if !mode.IsRegular() && !mode.IsDir() {
+		return fmt.Errorf("archive member %q is not a regular file", name)
+	}
+	clean := strings.ReplaceAll(name, `\`, "/")
+	if strings.HasPrefix(clean, "/") || (len(clean) > 1 && clean[1] == ':') {
+		return fmt.Errorf("archive member %q has an absolute path", name)
+	}
+	for _, part := range strings.Split(clean, "/") {
+		if part == ".." {
+			return fmt.Errorf("archive member %q escapes the archive root", name)
+		}
+	}
+	if path.Clean(clean) == "." && !mode.IsDir() {
+		return fmt.Errorf("archive member %q has no name", name)
+	}
+	if size > maxMemberBytes {
+		return fmt.Errorf("archive member %q is too large (%d bytes)", name, size)
+	}
+	return nil
+}
+
+// checkTarMember is checkMember for tar headers. Hard links carry no type
+// bits in FileInfo().Mode(), so they are caught by their type flag.
+func checkTarMember(h *tar.Header) error {
+	if h.Typeflag == tar.TypeLink {
+		return fmt.Errorf("archive member %q is not a regular file", h.Name)
+	}
+	return checkMember(h.Name, h.FileInfo().Mode(), h.Size)
+}
+
+// copyLimited copies at most maxMemberBytes, failing instead of truncating
+// when r holds more than its header declared.
+func copyLimited(w io.Writer, r io.Reader) error {
+	n, err := io.Copy(w, io.LimitReader(r, maxMemberBytes+1))
+	if err != nil {
+		return err
+	}
+	if n > maxMemberBytes {
+		return fmt.Errorf("member exceeds %d bytes", maxMemberBytes)
+	}
+	return nil
+}
+
+var (
+	elfMachines = map[string]elf.Machine{
+		"386":      elf.EM_386,
+		"amd64":    elf.EM_X86_64,
+		"arm":      elf.EM_ARM,
+		"arm64":    elf.EM_AARCH64,
+		"loong64":  elf.EM_LOONGARCH,
+		"mips":     elf.EM_MIPS,
+		"mipsle":   elf.EM_MIPS,
+		"mips64":   elf.EM_MIPS,
+		"mips64le": elf.EM_MIPS,
+		"ppc64":    elf.EM_PPC64,
+		"ppc64le":  elf.EM_PPC64,
+		"riscv64":  elf.EM_RISCV,
+		"s390x":    elf.EM_S390,
+	}
+	machoCPUs = map[string]macho.Cpu{
+		"amd64": macho.CpuAmd64,
+		"arm64": macho.CpuArm64,
+	}
+	peMachines = map[string]uint16{
+		"386":   pe.IMAGE_FILE_MACHINE_I386,
+		"amd64": pe.IMAGE_FILE_MACHINE_AMD64,
+		"arm64": pe.IMAGE_FILE_MACHINE_ARM64,
+	}
+)
+
+// checkExecutable verifies that path is a native executable for goos/goarch:
+// Mach-O on darwin, PE on windows, ELF elsewhere. Architectures missing from
+// the tables above are accepted in any variant of the right format.
+func checkExecutable(path, goos, goarch string) error {
+	switch goos {
+	case "darwin":
+		return checkMachO(path, goarch)
+	case "windows":
+		return checkPE(path, goarch)
+	default:
+		return checkELF(path, goarch)
+	}
+}
+
+func checkELF(path, goarch string) error {
+	f, err := elf.Open(path)
+	if err != nil {
+		return fmt.Errorf("extracted core is not an ELF executable: %w", err)
+	}
+	defer f.Close()
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+		return fmt.Errorf("extracted core is an ELF %s, not an executable", f.Type)
+	}
+	want, ok := elfMachines[goarch]
+	if !ok {
+		return nil
+	}
+	if f.Machine != want {
+		return fmt.Errorf("extracted core is built for %s, want %s", f.Machine, goarch)
+	}
+	if is64 := f.Class == elf.ELFCLASS64; is64 != is64Bit(goarch) {
+		return fmt.Errorf("extracted core has the wrong word size (%s) for %s", f.Class, goarch)
+	}
+	if strings.HasPrefix(goarch, "mips") || strings.HasPrefix(goarch, "ppc64") {
+		little := strings.HasSuffix(goarch, "le")
+		if (f.Data == elf.ELFDATA2LSB) != little {
+			return fmt.Errorf("extracted core has the wrong byte order for %s", goarch)
+		}
+	}
+	return nil
+}
+
+func is64Bit(goarch string) bool {
+	switch goarch {
+	case "amd64", "arm64", "loong64", "mips64", "mips64le", "ppc64", "ppc64le", "riscv64", "s390x":
+		return true
+	}
+	return false
+}
+
+func checkMachO(path, goarch string) error {
+	want, known := machoCPUs[goarch]
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		if known && f.Cpu != want {
+			return fmt.Errorf("extracted core is built for %s, want %s", f.Cpu, goarch)
+		}
+		return nil
+	}
+	fat, err := macho.OpenFat(path)
+	if err != nil {
+		return errors.New("extracted core is not a Mach-O executable")
+	}
+	defer fat.Close()
+	if !known {
+		return nil
+	}
+	for _, a := range fat.Arches {
+		if a.Cpu == want {
+			return nil
+		}
+	}
+	return fmt.Errorf("extracted universal binary has no %s slice", goarch)
+}
+
+func checkPE(path, goarch string) error {
+	f, err := pe.Open(path)
+	if err != nil {
+		return fmt.Errorf("extracted core is not a PE executable: %w", err)
+	}
+	defer f.Close()
+	if want, ok := peMachines[goarch]; ok && f.Machine != want {
+		return fmt.Errorf("extracted core is built for machine %#x, want %s", f.Machine, goarch)
+	}
+	return nil
+}
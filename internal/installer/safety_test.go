@@ -0,0 +1,154 @@
+package installer
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// rawZip writes members with explicit headers, which zipArchive cannot.
+func rawZip(t *testing.T, headers []*zip.FileHeader, body string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, fh := range headers {
+		var w io.Writer
+		var err error
+		if fh.UncompressedSize64 > 0 {
+			w, err = zw.CreateRaw(fh)
+		} else {
+			w, err = zw.CreateHeader(fh)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rawTarGz writes headers as given. Bodies are omitted, so a header claiming
+// a large Size leaves the archive truncated exactly like a real size bomb
+// would look to a reader that trusts the header.
+func rawTarGz(t *testing.T, headers []*tar.Header) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, h := range headers {
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if h.Typeflag == tar.TypeReg && h.Size <= 16 {
+			if _, err := tw.Write(bytes.Repeat([]byte("x"), int(h.Size))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	_ = tw.Flush()
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func symlinkZipHeader(name string) *zip.FileHeader {
+	fh := &zip.FileHeader{Name: name, Method: zip.Store}
+	fh.SetMode(os.ModeSymlink | 0o777)
+	return fh
+}
+
+func TestInstallFromFile_RejectsMaliciousArchives(t *testing.T) {
+	t.Parallel()
+
+	bomb := &zip.FileHeader{Name: "xray", Method: zip.Store, UncompressedSize64: maxMemberBytes + 1, CompressedSize64: 3}
+	cases := []struct {
+		name    string
+		file    string
+		archive []byte
+		want    string
+	}{
+		{"zip slip", "a.zip", zipArchive(t, map[string]string{"../../evil/xray": "bin"}), "escapes"},
+		{"zip absolute", "a.zip", zipArchive(t, map[string]string{"/usr/local/bin/xray": "bin"}), "absolute"},
+		{"zip drive letter", "a.zip", zipArchive(t, map[string]string{`C:\Windows\xray`: "bin"}), "absolute"},
+		{"zip symlink", "a.zip", rawZip(t, []*zip.FileHeader{symlinkZipHeader("xray")}, "/etc/passwd"), "symlink"},
+		{"zip bomb", "a.zip", rawZip(t, []*zip.FileHeader{bomb}, "bin"), "too large"},
+		{"tar slip", "a.tar.gz", tarGzArchive(t, map[string]string{"core/../../xray": "bin"}), "escapes"},
+		{"tar symlink", "a.tar.gz", rawTarGz(t, []*tar.Header{{Name: "xray", Typeflag: tar.TypeSymlink, Linkname: "/bin/sh", Mode: 0o777}}), "symlink"},
+		{"tar hard link", "a.tar.gz", rawTarGz(t, []*tar.Header{{Name: "xray", Typeflag: tar.TypeLink, Linkname: "/bin/sh", Mode: 0o755}}), "not a regular file"},
+		{"tar bomb", "a.tar.gz", rawTarGz(t, []*tar.Header{{Name: "xray", Typeflag: tar.TypeReg, Size: maxMemberBytes + 1, Mode: 0o755}}), "too large"},
+		{"not an executable", "a.zip", zipArchive(t, map[string]string{"xray": "#!/bin/sh\necho pwned\n"}), "executable"},
+	}
+	for _, c := range cases {
+		dir := t.TempDir()
+		archive := filepath.Join(dir, c.file)
+		if err := os.WriteFile(archive, c.archive, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		dest := filepath.Join(dir, "core")
+		_, err := InstallFromFile(archive, Options{DestDir: dest})
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Fatalf("%s: InstallFromFile() error = %v, want %q", c.name, err, c.want)
+		}
+		if entries, _ := os.ReadDir(dest); len(entries) != 0 {
+			t.Fatalf("%s: installed %d files from a rejected archive", c.name, len(entries))
+		}
+		if _, statErr := os.Stat(filepath.Join(filepath.Dir(dir), "evil")); !os.IsNotExist(statErr) {
+			t.Fatalf("%s: archive escaped the work dir", c.name)
+		}
+	}
+}
+
+func TestCheckExecutable(t *testing.T) {
+	t.Parallel()
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkExecutable(self, runtime.GOOS, runtime.GOARCH); err != nil {
+		t.Fatalf("checkExecutable(test binary) error = %v", err)
+	}
+	other := "arm64"
+	if runtime.GOARCH == "arm64" {
+		other = "amd64"
+	}
+	if err := checkExecutable(self, runtime.GOOS, other); err == nil {
+		t.Fatalf("checkExecutable(test binary, %s) error = nil, want architecture mismatch", other)
+	}
+}
+
+func TestCheckMember(t *testing.T) {
+	cases := []struct {
+		name string
+		mode os.FileMode
+		size int64
+		ok   bool
+	}{
+		{"Xray-linux-64/xray", 0o755, 30 << 20, true},
+		{"geoip.dat", 0o644, 0, true},
+		{"docs/", os.ModeDir | 0o755, 0, true},
+		{"..", os.ModeDir | 0o755, 0, false},
+		{`..\xray`, 0o755, 1, false},
+		{"xray", os.ModeNamedPipe, 0, false},
+		{"xray", os.ModeDevice, 0, false},
+		{"./", 0o644, 1, false},
+	}
+	for _, c := range cases {
+		if err := checkMember(c.name, c.mode, c.size); (err == nil) != c.ok {
+			t.Fatalf("checkMember(%q, %v) error = %v, want ok=%v", c.name, c.mode, err, c.ok)
+		}
+	}
+}
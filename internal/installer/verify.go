@@ -0,0 +1,175 @@
+package installer
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const maxDigestBytes = 64 << 10
+
+// checksumSuffixes are the sibling checksum assets looked for, in order:
+// Xray/V2Ray publish .dgst, rule-set releases usually .sha256sum.
+var checksumSuffixes = []string{".dgst", ".sha256sum", ".sha512sum"}
+
+// ChecksumError reports an archive whose digest does not match the value
+// published next to it in the release.
+type ChecksumError struct {
+	Asset     string
+	Algorithm string
+	Want      string
+	Got       string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: %s want %s, got %s", e.Asset, e.Algorithm, e.Want, e.Got)
+}
+
+// digestAlgorithms lists the algorithms checked, strongest first. Keys are the
+// names used in Xray/V2Ray .dgst files.
+var digestAlgorithms = []struct {
+	name string
+	size int
+	new  func() hash.Hash
+}{
+	{"SHA2-512", sha512.Size, sha512.New},
+	{"SHA2-256", sha256.Size, sha256.New},
+}
+
+// verifyAsset downloads the checksum sibling of selected (.dgst or
+// .sha256sum) and checks the file at archivePath against every SHA-2 digest
+// it lists. A release without a checksum file fails closed. The checksum is
+// fetched from its release URL, never the mirror, so that a mirror serving a
+// forged archive cannot forge its digest too.
+func (r remote) verifyAsset(ctx context.Context, assets []asset, selected asset, archivePath string) error {
+	dgst := checksumAsset(assets, selected.Name)
+	if dgst == nil {
+		return fmt.Errorf("release has no checksum file for %s (use --skip-verify to install anyway)", selected.Name)
+	}
+
+	body, err := r.fetchSmall(ctx, dgst.URL, maxDigestBytes)
+	if err != nil {
+		if r.mirror != "" {
+			return fmt.Errorf("fetch checksum file from the release host (it is never taken from the mirror; use --skip-verify to install anyway): %w", err)
+		}
+		return fmt.Errorf("fetch checksum file: %w", err)
+	}
+	digests := parseDigests(body)
+	return verifyFile(archivePath, selected.Name, digests)
+}
+
+func checksumAsset(assets []asset, name string) *asset {
+	for _, suffix := range checksumSuffixes {
+		for i := range assets {
+			if strings.EqualFold(assets[i].Name, name+suffix) {
+				return &assets[i]
+			}
+		}
+	}
+	return nil
+}
+
+// verifyFile hashes path and compares it with the SHA-2 entries of digests.
+// At least one of them must be present.
+func verifyFile(path, name string, digests map[string]string) error {
+	var checked int
+	for _, alg := range digestAlgorithms {
+		want, ok := digests[alg.name]
+		if !ok {
+			continue
+		}
+		got, err := hashFile(path, alg.new())
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(got, want) {
+			return &ChecksumError{Asset: name, Algorithm: alg.name, Want: strings.ToLower(want), Got: got}
+		}
+		checked++
+	}
+	if checked == 0 {
+		return fmt.Errorf("checksum file for %s has no SHA-256 or SHA-512 digest", name)
+	}
+	return nil
+}
+
+// parseDigests reads the "SHA2-256= <hex>" lines of a .dgst file. Plain
+// "<hex>  <file>" lines, as written by sha256sum/sha512sum, are recognised by
+// digest length.
+func parseDigests(body []byte) map[string]string {
+	digests := make(map[string]string)
+	sc := bufio.NewScanner(strings.NewReader(string(body)))
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" {
+			continue
+		}
+		if name, value, ok := strings.Cut(line, "="); ok {
+			name = strings.ToUpper(strings.TrimSpace(name))
+			switch name {
+			case "SHA256":
+				name = "SHA2-256"
+			case "SHA512":
+				name = "SHA2-512"
+			}
+			digests[name] = strings.TrimSpace(value)
+			continue
+		}
+		field := strings.Fields(line)[0]
+		if _, err := hex.DecodeString(field); err != nil {
+			continue
+		}
+		for _, alg := range digestAlgorithms {
+			if len(field) == alg.size*2 {
+				digests[alg.name] = field
+			}
+		}
+	}
+	return digests
+}
+
+func hashFile(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("open archive for checksum: %w", err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("hash archive: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (r remote) fetchSmall(ctx context.Context, rawURL string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("User-Agent", "proxy-node")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > limit {
+		return nil, errors.New("response too large")
+	}
+	return body, nil
+}
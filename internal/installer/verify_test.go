@@ -0,0 +1,131 @@
+package installer
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const fakeArchive = "fake Xray-linux-64.zip contents"
+
+func xrayDigest(body string) string {
+	s256 := sha256.Sum256([]byte(body))
+	s512 := sha512.Sum512([]byte(body))
+	return fmt.Sprintf("MD5= 0123\nSHA1= 4567\nSHA2-256= %s\nSHA2-512= %s\n", hex.EncodeToString(s256[:]), hex.EncodeToString(s512[:]))
+}
+
+// fakeRelease serves an archive and, when dgst is non-empty, its .dgst file.
+func fakeRelease(t *testing.T, dgst string) ([]asset, string) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Xray-linux-64.zip", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(fakeArchive))
+	})
+	mux.HandleFunc("/Xray-linux-64.zip.dgst", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(dgst))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	assets := []asset{{Name: "Xray-linux-64.zip", URL: srv.URL + "/Xray-linux-64.zip"}}
+	if dgst != "" {
+		assets = append(assets, asset{Name: "Xray-linux-64.zip.dgst", URL: srv.URL + "/Xray-linux-64.zip.dgst"})
+	}
+
+	path := filepath.Join(t.TempDir(), "Xray-linux-64.zip")
+	if err := newRemote(Options{}).downloadFile(context.Background(), assets[0].URL, path); err != nil {
+		t.Fatalf("downloadFile() error = %v", err)
+	}
+	return assets, path
+}
+
+func TestVerifyAsset(t *testing.T) {
+	t.Parallel()
+
+	assets, path := fakeRelease(t, xrayDigest(fakeArchive))
+	if err := newRemote(Options{}).verifyAsset(context.Background(), assets, assets[0], path); err != nil {
+		t.Fatalf("verifyAsset() error = %v", err)
+	}
+}
+
+func TestVerifyAsset_Mismatch(t *testing.T) {
+	t.Parallel()
+
+	assets, path := fakeRelease(t, xrayDigest("tampered"))
+	err := newRemote(Options{}).verifyAsset(context.Background(), assets, assets[0], path)
+	var mismatch *ChecksumError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("verifyAsset() error = %v, want *ChecksumError", err)
+	}
+	if mismatch.Algorithm != "SHA2-512" || mismatch.Asset != "Xray-linux-64.zip" {
+		t.Fatalf("mismatch = %+v", mismatch)
+	}
+}
+
+func TestVerifyAsset_MissingDigestFailsClosed(t *testing.T) {
+	t.Parallel()
+
+	assets, path := fakeRelease(t, "")
+	err := newRemote(Options{}).verifyAsset(context.Background(), assets, assets[0], path)
+	if err == nil || !strings.Contains(err.Error(), "--skip-verify") {
+		t.Fatalf("verifyAsset() error = %v, want missing checksum error", err)
+	}
+}
+
+func TestVerifyAsset_NoSHA2Digest(t *testing.T) {
+	t.Parallel()
+
+	assets, path := fakeRelease(t, "MD5= 0123\n")
+	if err := newRemote(Options{}).verifyAsset(context.Background(), assets, assets[0], path); err == nil {
+		t.Fatal("verifyAsset() error = nil, want error")
+	}
+}
+
+func TestParseDigests(t *testing.T) {
+	t.Parallel()
+
+	sum := sha256.Sum256([]byte("x"))
+	hexSum := hex.EncodeToString(sum[:])
+	cases := []struct {
+		body string
+		want map[string]string
+	}{
+		{"MD5= aa\r\nSHA2-256= " + hexSum + "\r\n", map[string]string{"MD5": "aa", "SHA2-256": hexSum}},
+		{hexSum + "  Xray-linux-64.zip\n", map[string]string{"SHA2-256": hexSum}},
+		{"SHA256= " + hexSum, map[string]string{"SHA2-256": hexSum}},
+	}
+	for _, c := range cases {
+		got := parseDigests([]byte(c.body))
+		if len(got) != len(c.want) {
+			t.Fatalf("parseDigests(%q) = %v, want %v", c.body, got, c.want)
+		}
+		for k, v := range c.want {
+			if got[k] != v {
+				t.Fatalf("parseDigests(%q)[%s] = %q, want %q", c.body, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestVerifyFile_IgnoresCase(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "a.zip")
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256([]byte("x"))
+	digests := map[string]string{"SHA2-256": strings.ToUpper(hex.EncodeToString(sum[:]))}
+	if err := verifyFile(path, "a.zip", digests); err != nil {
+		t.Fatalf("verifyFile() error = %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// URIEncoder is implemented by providers that can render themselves back
+// into a share link. Links are canonical: query parameters are sorted,
+// parser defaults and empty values are dropped, so two links for the same
+// node encode to the same string apart from the remark.
+type URIEncoder interface {
+	URI() (string, error)
+}
+
+// remarkless is implemented by the built-in providers to encode without the
+// remark, which lives inside the payload for vmess.
+type remarkless interface {
+	uriWithoutRemark() (string, error)
+}
+
+// CanonicalURI returns the canonical share link for p without its remark,
+// suitable as a dedupe key.
+func CanonicalURI(p Provider) (string, error) {
+	if r, ok := p.(remarkless); ok {
+		return r.uriWithoutRemark()
+	}
+	e, ok := p.(URIEncoder)
+	if !ok {
+		return "", fmt.Errorf("%s provider cannot be encoded as a URI", p.Name())
+	}
+	link, err := e.URI()
+	if err != nil {
+		return "", err
+	}
+	link, _, _ = strings.Cut(link, "#")
+	return link, nil
+}
+
+// URI returns the canonical link for p including its remark.
+func URI(p Provider) (string, error) {
+	e, ok := p.(URIEncoder)
+	if !ok {
+		return "", fmt.Errorf("%s provider cannot be encoded as a URI", p.Name())
+	}
+	return e.URI()
+}
+
+func (v *VLESS) URI() (string, error) {
+	link, err := v.uriWithoutRemark()
+	return withRemark(link, v.Remark), err
+}
+
+func (v *VLESS) uriWithoutRemark() (string, error) {
+	q := url.Values{}
+	setParam(q, "flow", v.Flow, "")
+	setParam(q, "encryption", v.Encryption, "none")
+	setParam(q, "type", v.Network, "tcp")
+	setParam(q, "security", v.Security, "none")
+	setParam(q, "headerType", v.HeaderType, "")
+	setParam(q, "host", v.Host, "")
+	setParam(q, "path", v.Path, "")
+	setParam(q, "sni", v.SNI, "")
+	setParam(q, "alpn", v.ALPN, "")
+	setParam(q, "serviceName", v.Service, "")
+	setParam(q, "fp", v.Fingerprint, "")
+	setParam(q, "pbk", v.PublicKey, "")
+	setParam(q, "sid", v.ShortID, "")
+	setParam(q, "spx", v.SpiderX, "")
+	setParam(q, "pqv", v.PQV, "")
+	return shareLink("vless", url.User(v.ID), v.Address, strconv.Itoa(v.Port), q), nil
+}
+
+func (t *Trojan) URI() (string, error) {
+	link, err := t.uriWithoutRemark()
+	return withRemark(link, t.Remark), err
+}
+
+func (t *Trojan) uriWithoutRemark() (string, error) {
+	q := url.Values{}
+	setParam(q, "flow", t.Flow, "")
+	setParam(q, "type", t.Network, "tcp")
+	setParam(q, "security", t.Security, "tls")
+	setParam(q, "headerType", t.HeaderType, "")
+	setParam(q, "host", t.Host, "")
+	setParam(q, "path", t.Path, "")
+	setParam(q, "sni", t.SNI, "")
+	setParam(q, "alpn", t.ALPN, "")
+	setParam(q, "serviceName", t.Service, "")
+	setParam(q, "fp", t.Fingerprint, "")
+	setParam(q, "pbk", t.PublicKey, "")
+	setParam(q, "sid", t.ShortID, "")
+	setParam(q, "spx", t.SpiderX, "")
+	setParam(q, "pqv", t.PQV, "")
+	return shareLink("trojan", url.User(t.Password), t.Address, strconv.Itoa(t.Port), q), nil
+}
+
+// URI encodes SIP002 style: base64url(method:password) without padding.
+func (s *Shadowsocks) URI() (string, error) {
+	link, err := s.uriWithoutRemark()
+	return withRemark(link, s.Remark), err
+}
+
+func (s *Shadowsocks) uriWithoutRemark() (string, error) {
+	q := url.Values{}
+	setParam(q, "type", s.Network, "tcp")
+	setParam(q, "security", s.Security, "none")
+	setParam(q, "headerType", s.HeaderType, "")
+	setParam(q, "host", s.Host, "")
+	setParam(q, "path", s.Path, "")
+	setParam(q, "sni", s.SNI, "")
+	setParam(q, "alpn", s.ALPN, "")
+	setParam(q, "serviceName", s.Service, "")
+	cred := base64.RawURLEncoding.EncodeToString([]byte(s.Method + ":" + s.Password))
+	return shareLink("ss", url.User(cred), s.Address, strconv.Itoa(s.Port), q), nil
+}
+
+func (h *Hysteria2) URI() (string, error) {
+	link, err := h.uriWithoutRemark()
+	return withRemark(link, h.Remark), err
+}
+
+func (h *Hysteria2) uriWithoutRemark() (string, error) {
+	q := url.Values{}
+	setParam(q, "sni", h.SNI, "")
+	if h.Insecure {
+		q.Set("insecure", "1")
+	}
+	setParam(q, "obfs", h.Obfs, "")
+	setParam(q, "obfs-password", h.ObfsPassword, "")
+	setParam(q, "pinSHA256", h.PinSHA256, "")
+	setParam(q, "alpn", h.ALPN, "")
+
+	ports := h.Ports
+	if ports == "" {
+		ports = strconv.Itoa(h.Port)
+	}
+	var user *url.Userinfo
+	if h.Auth != "" {
+		user = url.User(h.Auth)
+	}
+	return shareLink("hysteria2", user, h.Address, ports, q), nil
+}
+
+// URI encodes the v2rayN JSON form with sorted keys, string ports and
+// standard padded base64.
+func (v *VMess) URI() (string, error) {
+	return v.encode(true)
+}
+
+func (v *VMess) uriWithoutRemark() (string, error) {
+	return v.encode(false)
+}
+
+func (v *VMess) encode(remark bool) (string, error) {
+	m := map[string]string{
+		"v":    "2",
+		"add":  v.Address,
+		"port": strconv.Itoa(v.Port),
+		"id":   v.ID,
+		"aid":  strconv.Itoa(v.AlterID),
+	}
+	setField := func(key, value, def string) {
+		if value != "" && !strings.EqualFold(value, def) {
+			m[key] = value
+		}
+	}
+	setField("net", v.Network, "tcp")
+	setField("host", v.Host, "")
+	setField("path", v.Path, "")
+	setField("tls", v.TLS, "")
+	setField("sni", v.SNI, "")
+	setField("alpn", v.ALPN, "")
+	setField("type", v.Type, "none")
+	setField("scy", v.Security, "auto")
+	if remark {
+		setField("ps", v.Remark, "")
+	}
+
+	body, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("marshal vmess JSON: %w", err)
+	}
+	return "vmess://" + base64.StdEncoding.EncodeToString(body), nil
+}
+
+func setParam(q url.Values, key, value, def string) {
+	if value == "" || strings.EqualFold(value, def) {
+		return
+	}
+	q.Set(key, value)
+}
+
+func withRemark(link, remark string) string {
+	if remark == "" {
+		return link
+	}
+	return link + "#" + url.PathEscape(remark)
+}
+
+func shareLink(scheme string, user *url.Userinfo, host, port string, q url.Values) string {
+	u := url.URL{
+		Scheme:   scheme,
+		User:     user,
+		Host:     net.JoinHostPort(host, port),
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonicalURI_RoundTrip(t *testing.T) {
+	links := []string{
+		"vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?type=tcp&headerType=http&security=reality&pbk=PUBKEY123&sid=abcd1234&fp=chrome&sni=aparat.com&spx=%2F&pqv=VERIFY123&encryption=none&path=%2Ftest",
+		"trojan://p%40ss@[2001:db8::1]:443?security=tls&type=ws&sni=cdn.example.com&host=ws.example.com&path=%2Fws",
+		"ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ=@example.com:8388?type=tcp&security=tls&sni=aparat.com",
+		"hy2://user%3Apass@example.com:443,20000-30000?sni=example.com&insecure=1&obfs=salamander&obfs-password=cry",
+		vmessURI(t, map[string]any{"v": "2", "add": "example.com", "port": 443, "id": "2d67b1be-5e23-40b0-a826-4fd8dd4e650f", "aid": 0, "net": "ws", "tls": "tls", "path": "/ws", "host": "example.com"}),
+	}
+	for _, raw := range links {
+		p, err := FromURI(raw)
+		if err != nil {
+			t.Fatalf("FromURI(%q) error = %v", raw, err)
+		}
+		first, err := CanonicalURI(p)
+		if err != nil {
+			t.Fatalf("CanonicalURI(%s) error = %v", p.Name(), err)
+		}
+		again, err := FromURI(first)
+		if err != nil {
+			t.Fatalf("FromURI(canonical %q) error = %v", first, err)
+		}
+		second, err := CanonicalURI(again)
+		if err != nil {
+			t.Fatalf("CanonicalURI(again) error = %v", err)
+		}
+		if first != second {
+			t.Fatalf("canonical link not stable for %s:\n first  %q\n second %q", p.Name(), first, second)
+		}
+	}
+}
+
+func TestCanonicalURI_EquivalentLinksMatch(t *testing.T) {
+	pairs := [][2]string{
+		{
+			"vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?type=ws&security=tls&path=%2Fws&sni=a.com",
+			"vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?sni=a.com&path=%2Fws&encryption=none&security=tls&type=ws#remark",
+		},
+		{
+			"ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ=@example.com:8388",
+			"ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ@example.com:8388?type=tcp",
+		},
+		{
+			vmessURI(t, map[string]any{"v": "2", "add": "example.com", "port": 443, "id": "2d67b1be-5e23-40b0-a826-4fd8dd4e650f", "aid": 0}),
+			vmessURI(t, map[string]any{"id": "2d67b1be-5e23-40b0-a826-4fd8dd4e650f", "port": "443", "aid": "0", "add": "example.com", "net": "tcp", "scy": "auto"}),
+		},
+		{
+			"hy2://secret@example.com:443",
+			"hysteria2://secret@example.com/",
+		},
+	}
+	for _, pair := range pairs {
+		a := mustCanonical(t, pair[0])
+		b := mustCanonical(t, pair[1])
+		if a != b {
+			t.Fatalf("canonical links differ:\n %q\n %q", a, b)
+		}
+	}
+}
+
+func TestCanonicalURI_VLESSSortedAndDefaultsDropped(t *testing.T) {
+	got := mustCanonical(t, "vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?type=ws&security=tls&encryption=none&path=%2Fws")
+	want := "vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?path=%2Fws&security=tls&type=ws"
+	if got != want {
+		t.Fatalf("CanonicalURI() = %q, want %q", got, want)
+	}
+}
+
+func TestCanonicalURI_Unsupported(t *testing.T) {
+	_, err := CanonicalURI(&fakeProvider{})
+	if err == nil || !strings.Contains(err.Error(), "cannot be encoded") {
+		t.Fatalf("CanonicalURI(fake) error = %v, want unsupported error", err)
+	}
+}
+
+func mustCanonical(t *testing.T, raw string) string {
+	t.Helper()
+	p, err := FromURI(raw)
+	if err != nil {
+		t.Fatalf("FromURI(%q) error = %v", raw, err)
+	}
+	out, err := CanonicalURI(p)
+	if err != nil {
+		t.Fatalf("CanonicalURI(%q) error = %v", raw, err)
+	}
+	return out
+}
+
+func TestURI_KeepsRemark(t *testing.T) {
+	links := []string{
+		"trojan://secret@example.com:443#%F0%9F%87%A9%F0%9F%87%AA%20Frankfurt",
+		vmessURI(t, map[string]any{"add": "example.com", "port": 443, "id": "2d67b1be-5e23-40b0-a826-4fd8dd4e650f", "ps": "🇺🇸 US-1"}),
+	}
+	for _, raw := range links {
+		p, err := FromURI(raw)
+		if err != nil {
+			t.Fatalf("FromURI(%q) error = %v", raw, err)
+		}
+		link, err := URI(p)
+		if err != nil {
+			t.Fatalf("URI() error = %v", err)
+		}
+		again, err := FromURI(link)
+		if err != nil {
+			t.Fatalf("FromURI(%q) error = %v", link, err)
+		}
+		if LabelOf(again) != LabelOf(p) {
+			t.Fatalf("remark after round trip = %q, want %q", LabelOf(again), LabelOf(p))
+		}
+
+		canonical, err := CanonicalURI(p)
+		if err != nil {
+			t.Fatalf("CanonicalURI() error = %v", err)
+		}
+		stripped, err := FromURI(canonical)
+		if err != nil {
+			t.Fatalf("FromURI(%q) error = %v", canonical, err)
+		}
+		if LabelOf(stripped) != "" {
+			t.Fatalf("CanonicalURI() kept remark %q", LabelOf(stripped))
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package provider
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// hysteria2Parser handles both hysteria2:// and hy2:// links. Port hopping
+// links carry ranges in the authority ("host:443,20000-30000"), which
+// net/url rejects, so the parser works on the raw string.
+type hysteria2Parser struct {
+	scheme string
+}
+
+func (p *hysteria2Parser) Scheme() string { return p.scheme }
+
+func (p *hysteria2Parser) Parse(_ *url.URL, raw string) (Provider, error) {
+	return p.ParseRaw(raw)
+}
+
+func (p *hysteria2Parser) ParseRaw(raw string) (Provider, error) {
+	_, rest, ok := strings.Cut(raw, "://")
+	if !ok {
+		return nil, errors.New("invalid hysteria2 URI")
+	}
+	remark := ""
+	if i := strings.Index(rest, "#"); i >= 0 {
+		rest, remark = rest[:i], decodeFragment(rest[i+1:])
+	}
+	query := ""
+	if i := strings.Index(rest, "?"); i >= 0 {
+		rest, query = rest[:i], rest[i+1:]
+	}
+	rest = strings.TrimSuffix(rest, "/")
+
+	auth := ""
+	server := rest
+	if at := strings.LastIndex(rest, "@"); at >= 0 {
+		decoded, err := url.PathUnescape(rest[:at])
+		if err != nil {
+			return nil, fmt.Errorf("hysteria2 auth decode failed: %w", err)
+		}
+		auth, server = decoded, rest[at+1:]
+	}
+
+	host, portSpec, err := splitHysteriaServer(server)
+	if err != nil {
+		return nil, err
+	}
+	q, err := url.ParseQuery(query)
+	if err != nil {
+		return nil, fmt.Errorf("hysteria2 query parse failed: %w", err)
+	}
+	if mport := strings.TrimSpace(q.Get("mport")); mport != "" {
+		portSpec = mport
+	}
+	port, ports, err := parseHysteriaPorts(portSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	obfs := q.Get("obfs")
+	if obfs != "" && !strings.EqualFold(obfs, "salamander") {
+		return nil, fmt.Errorf("unsupported hysteria2 obfs %q", obfs)
+	}
+	if obfs != "" && q.Get("obfs-password") == "" {
+		return nil, errors.New("hysteria2 obfs requires obfs-password")
+	}
+
+	return &Hysteria2{
+		Address:      host,
+		Port:         port,
+		Ports:        ports,
+		Auth:         auth,
+		SNI:          firstNonEmpty(q.Get("sni"), q.Get("peer")),
+		Insecure:     q.Get("insecure") == "1" || strings.EqualFold(q.Get("insecure"), "true"),
+		Obfs:         strings.ToLower(obfs),
+		ObfsPassword: q.Get("obfs-password"),
+		PinSHA256:    q.Get("pinSHA256"),
+		ALPN:         q.Get("alpn"),
+		Remark:       remark,
+	}, nil
+}
+
+func splitHysteriaServer(server string) (string, string, error) {
+	if server == "" {
+		return "", "", errors.New("hysteria2 URI missing host")
+	}
+	if strings.HasPrefix(server, "[") {
+		end := strings.Index(server, "]")
+		if end < 0 {
+			return "", "", errors.New("hysteria2 host/port parse failed: missing ']'")
+		}
+		host := server[1:end]
+		rest := server[end+1:]
+		if rest == "" {
+			return host, "", nil
+		}
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", fmt.Errorf("hysteria2 host/port parse failed: %q", server)
+		}
+		return host, rest[1:], nil
+	}
+	host, portSpec, ok := strings.Cut(server, ":")
+	if !ok {
+		return server, "", nil
+	}
+	if host == "" {
+		return "", "", errors.New("hysteria2 URI missing host")
+	}
+	return host, portSpec, nil
+}
+
+// parseHysteriaPorts returns the dial port and, when the spec lists more than
+// one port, the normalized hopping list ("20000-30000,40000").
+func parseHysteriaPorts(spec string) (int, string, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return 443, "", nil
+	}
+	parts := splitCSV(spec)
+	if len(parts) == 0 {
+		return 0, "", errors.New("invalid hysteria2 port")
+	}
+	first := 0
+	for _, part := range parts {
+		lo, hi, isRange := strings.Cut(part, "-")
+		start, err := parsePort(lo)
+		if err != nil {
+			return 0, "", fmt.Errorf("invalid hysteria2 port %q", part)
+		}
+		if isRange {
+			end, err := parsePort(hi)
+			if err != nil || end < start {
+				return 0, "", fmt.Errorf("invalid hysteria2 port range %q", part)
+			}
+		}
+		if first == 0 {
+			first = start
+		}
+	}
+	if len(parts) == 1 && !strings.Contains(parts[0], "-") {
+		return first, "", nil
+	}
+	return first, strings.Join(parts, ","), nil
+}
+
+func parsePort(v string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0, err
+	}
+	if n < 1 || n > 65535 {
+		return 0, errors.New("port out of range")
+	}
+	return n, nil
+}
+
+func (h *Hysteria2) Name() string { return "hysteria2" }
+
+func (h *Hysteria2) Label() string { return h.Remark }
+
+func (h *Hysteria2) Endpoint() string {
+	return net.JoinHostPort(h.Address, strconv.Itoa(h.Port))
+}
+
+func (h *Hysteria2) Outbound() (map[string]any, error) {
+	out := map[string]any{
+		"tag":      "proxy",
+		"protocol": "hysteria",
+		"settings": map[string]any{
+			"version": 2,
+			"address": h.Address,
+			"port":    h.Port,
+		},
+	}
+
+	hysteria := map[string]any{
+		"version": 2,
+		"auth":    h.Auth,
+	}
+	if h.Ports != "" {
+		hysteria["udphop"] = map[string]any{"port": h.Ports}
+	}
+
+	tls := map[string]any{
+		"serverName": firstNonEmpty(h.SNI, h.Address),
+		"alpn":       splitCSV(valueOrDefault(h.ALPN, "h3")),
+	}
+	if h.Insecure {
+		tls["allowInsecure"] = true
+	}
+	if pin := strings.TrimSpace(h.PinSHA256); pin != "" {
+		chainHash, err := pinnedChainHash(pin)
+		if err != nil {
+			return nil, err
+		}
+		tls["pinnedPeerCertificateChainSha256"] = []string{chainHash}
+	}
+
+	stream := map[string]any{
+		"network":          "hysteria",
+		"security":         "tls",
+		"tlsSettings":      tls,
+		"hysteriaSettings": hysteria,
+	}
+	if h.Obfs == "salamander" {
+		stream["finalmask"] = map[string]any{
+			"udp": []any{map[string]any{
+				"type":     "salamander",
+				"settings": map[string]any{"password": h.ObfsPassword},
+			}},
+		}
+	}
+
+	out["streamSettings"] = stream
+	return out, nil
+}
+
+// pinnedChainHash converts a pinSHA256 value (the hex SHA-256 of the server
+// certificate, optionally colon-separated as openssl prints it) to Xray's
+// pinnedPeerCertificateChainSha256, the base64 hash of the certificate chain.
+// Both agree for servers presenting a single certificate, as self-signed
+// hysteria servers do.
+func pinnedChainHash(pin string) (string, error) {
+	raw, err := hex.DecodeString(strings.NewReplacer(":", "", "-", "", " ", "").Replace(pin))
+	if err != nil || len(raw) != sha256.Size {
+		return "", fmt.Errorf("hysteria2 pinSHA256 must be a hex SHA-256 fingerprint, got %q", pin)
+	}
+	return base64.StdEncoding.EncodeToString(raw), nil
+}
@@ -1,10 +1,20 @@
 package provider
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
 	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestFromURI_VMess_PortAndAidNumeric(t *testing.T) {
@@ -265,7 +275,7 @@ func TestFromURI_VMess_PortOutOfRange(t *testing.T) {
 }
 
 func TestFromURI_UnsupportedScheme(t *testing.T) {
-	_, err := FromURI("trojan://example")
+	_, err := FromURI("unknown://example")
 	if err == nil {
 		t.Fatal("FromURI() expected unsupported scheme error")
 	}
@@ -327,6 +337,257 @@ func TestShadowsocksOutbound_TLS(t *testing.T) {
 	}
 }
 
+func TestFromURI_Trojan_WSFieldsMapped(t *testing.T) {
+	raw := "trojan://p%40ss@example.com:443?security=tls&type=ws&sni=cdn.example.com&host=ws.example.com&path=%2Fws#node"
+	p, err := FromURI(raw)
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	tr, ok := p.(*Trojan)
+	if !ok {
+		t.Fatalf("provider type = %T, want *Trojan", p)
+	}
+	if tr.Password != "p@ss" {
+		t.Fatalf("tr.Password = %q, want p@ss", tr.Password)
+	}
+	if tr.Port != 443 {
+		t.Fatalf("tr.Port = %d, want 443", tr.Port)
+	}
+	if tr.Network != "ws" {
+		t.Fatalf("tr.Network = %q, want ws", tr.Network)
+	}
+	if tr.SNI != "cdn.example.com" {
+		t.Fatalf("tr.SNI = %q, want cdn.example.com", tr.SNI)
+	}
+}
+
+func TestFromURI_Trojan_DefaultsToTLS(t *testing.T) {
+	p, err := FromURI("trojan://secret@example.com:443")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	tr, ok := p.(*Trojan)
+	if !ok {
+		t.Fatalf("provider type = %T, want *Trojan", p)
+	}
+	if tr.Security != "tls" {
+		t.Fatalf("tr.Security = %q, want tls", tr.Security)
+	}
+	if tr.Network != "tcp" {
+		t.Fatalf("tr.Network = %q, want tcp", tr.Network)
+	}
+}
+
+func TestFromURI_Trojan_MissingPassword(t *testing.T) {
+	_, err := FromURI("trojan://example.com:443")
+	if err == nil {
+		t.Fatal("FromURI() expected missing password error")
+	}
+	if !strings.Contains(err.Error(), "password") {
+		t.Fatalf("error = %v, want password error", err)
+	}
+}
+
+func TestTrojanOutbound_ServersAndStream(t *testing.T) {
+	tr := &Trojan{
+		Address:  "example.com",
+		Port:     443,
+		Password: "secret",
+		Network:  "ws",
+		Security: "tls",
+		Host:     "ws.example.com",
+		Path:     "/ws",
+		SNI:      "cdn.example.com",
+	}
+	out, err := tr.Outbound()
+	if err != nil {
+		t.Fatalf("Outbound() error = %v", err)
+	}
+	if got := out["protocol"]; got != "trojan" {
+		t.Fatalf("protocol = %#v, want trojan", got)
+	}
+	settings := mustMap(t, out["settings"])
+	servers, ok := settings["servers"].([]any)
+	if !ok || len(servers) != 1 {
+		t.Fatalf("settings.servers = %#v, want one server", settings["servers"])
+	}
+	server := mustMap(t, servers[0])
+	if got := server["password"]; got != "secret" {
+		t.Fatalf("server.password = %#v, want secret", got)
+	}
+	stream := mustMap(t, out["streamSettings"])
+	ws := mustMap(t, stream["wsSettings"])
+	if got := ws["path"]; got != "/ws" {
+		t.Fatalf("ws.path = %#v, want /ws", got)
+	}
+	tls := mustMap(t, stream["tlsSettings"])
+	if got := tls["serverName"]; got != "cdn.example.com" {
+		t.Fatalf("tls.serverName = %#v, want cdn.example.com", got)
+	}
+}
+
+func TestFromURI_Hysteria2_FieldsMapped(t *testing.T) {
+	raw := "hysteria2://user%3Apass@example.com:443/?sni=real.example.com&insecure=1&obfs=salamander&obfs-password=cry&pinSHA256=deadbeef#hy"
+	p, err := FromURI(raw)
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	hy, ok := p.(*Hysteria2)
+	if !ok {
+		t.Fatalf("provider type = %T, want *Hysteria2", p)
+	}
+	if hy.Auth != "user:pass" {
+		t.Fatalf("hy.Auth = %q, want user:pass", hy.Auth)
+	}
+	if hy.Port != 443 || hy.Ports != "" {
+		t.Fatalf("hy.Port/Ports = %d/%q, want 443/empty", hy.Port, hy.Ports)
+	}
+	if !hy.Insecure {
+		t.Fatal("hy.Insecure = false, want true")
+	}
+	if hy.Obfs != "salamander" || hy.ObfsPassword != "cry" {
+		t.Fatalf("hy.Obfs/ObfsPassword = %q/%q, want salamander/cry", hy.Obfs, hy.ObfsPassword)
+	}
+	if hy.PinSHA256 != "deadbeef" {
+		t.Fatalf("hy.PinSHA256 = %q, want deadbeef", hy.PinSHA256)
+	}
+}
+
+func TestHysteria2Outbound_PinnedCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "hy.example.com"}, NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(der)
+	// As printed by openssl x509 -fingerprint -sha256.
+	var parts []string
+	for _, b := range sum {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+	fingerprint := strings.Join(parts, ":")
+
+	p, err := FromURI("hy2://secret@hy.example.com:443?pinSHA256=" + url.QueryEscape(fingerprint))
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	out, err := p.Outbound()
+	if err != nil {
+		t.Fatalf("Outbound() error = %v", err)
+	}
+	tls := mustMap(t, mustMap(t, out["streamSettings"])["tlsSettings"])
+	// Xray's hash of a one-certificate chain is the certificate's SHA-256.
+	want := base64.StdEncoding.EncodeToString(sum[:])
+	if got, _ := tls["pinnedPeerCertificateChainSha256"].([]string); len(got) != 1 || got[0] != want {
+		t.Fatalf("pinnedPeerCertificateChainSha256 = %#v, want [%q]", tls["pinnedPeerCertificateChainSha256"], want)
+	}
+
+	bad := &Hysteria2{Address: "hy.example.com", Port: 443, PinSHA256: "deadbeef"}
+	if _, err := bad.Outbound(); err == nil || !strings.Contains(err.Error(), "pinSHA256") {
+		t.Fatalf("Outbound(short pin) error = %v", err)
+	}
+}
+
+func TestFromURI_Hysteria2_PortHopping(t *testing.T) {
+	p, err := FromURI("hy2://secret@example.com:443,20000-30000?sni=example.com")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	hy, ok := p.(*Hysteria2)
+	if !ok {
+		t.Fatalf("provider type = %T, want *Hysteria2", p)
+	}
+	if hy.Port != 443 {
+		t.Fatalf("hy.Port = %d, want 443", hy.Port)
+	}
+	if hy.Ports != "443,20000-30000" {
+		t.Fatalf("hy.Ports = %q, want 443,20000-30000", hy.Ports)
+	}
+
+	out, err := hy.Outbound()
+	if err != nil {
+		t.Fatalf("Outbound() error = %v", err)
+	}
+	stream := mustMap(t, out["streamSettings"])
+	settings := mustMap(t, stream["hysteriaSettings"])
+	hop := mustMap(t, settings["udphop"])
+	if got := hop["port"]; got != "443,20000-30000" {
+		t.Fatalf("udphop.port = %#v, want 443,20000-30000", got)
+	}
+}
+
+func TestFromURI_Hysteria2_DefaultPort(t *testing.T) {
+	p, err := FromURI("hy2://secret@example.com")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	if got := p.(*Hysteria2).Port; got != 443 {
+		t.Fatalf("hy.Port = %d, want 443", got)
+	}
+}
+
+func TestFromURI_Hysteria2_InvalidPortRange(t *testing.T) {
+	_, err := FromURI("hy2://secret@example.com:30000-20000")
+	if err == nil {
+		t.Fatal("FromURI() expected invalid port range error")
+	}
+	if !strings.Contains(err.Error(), "port range") {
+		t.Fatalf("error = %v, want port range error", err)
+	}
+}
+
+func TestFromURI_Hysteria2_UnsupportedObfs(t *testing.T) {
+	_, err := FromURI("hy2://secret@example.com:443?obfs=other&obfs-password=x")
+	if err == nil {
+		t.Fatal("FromURI() expected unsupported obfs error")
+	}
+	if !strings.Contains(err.Error(), "obfs") {
+		t.Fatalf("error = %v, want obfs error", err)
+	}
+}
+
+func TestHysteria2Outbound_SalamanderAndTLS(t *testing.T) {
+	hy := &Hysteria2{
+		Address:      "example.com",
+		Port:         443,
+		Auth:         "secret",
+		SNI:          "real.example.com",
+		Insecure:     true,
+		Obfs:         "salamander",
+		ObfsPassword: "cry",
+	}
+	out, err := hy.Outbound()
+	if err != nil {
+		t.Fatalf("Outbound() error = %v", err)
+	}
+	if got := out["protocol"]; got != "hysteria" {
+		t.Fatalf("protocol = %#v, want hysteria", got)
+	}
+	stream := mustMap(t, out["streamSettings"])
+	if got := stream["network"]; got != "hysteria" {
+		t.Fatalf("stream.network = %#v, want hysteria", got)
+	}
+	tls := mustMap(t, stream["tlsSettings"])
+	if got := tls["serverName"]; got != "real.example.com" {
+		t.Fatalf("tls.serverName = %#v, want real.example.com", got)
+	}
+	if got := tls["allowInsecure"]; got != true {
+		t.Fatalf("tls.allowInsecure = %#v, want true", got)
+	}
+	mask := mustMap(t, stream["finalmask"])
+	udp, ok := mask["udp"].([]any)
+	if !ok || len(udp) != 1 {
+		t.Fatalf("finalmask.udp = %#v, want one entry", mask["udp"])
+	}
+	if got := mustMap(t, udp[0])["type"]; got != "salamander" {
+		t.Fatalf("finalmask.udp[0].type = %#v, want salamander", got)
+	}
+}
+
 func vmessURI(t *testing.T, payload map[string]any) string {
 	t.Helper()
 	b, err := json.Marshal(payload)
@@ -366,3 +627,42 @@ func mustStringSlice(t *testing.T, v any) []string {
 	}
 	return out
 }
+
+func TestEndpointOf(t *testing.T) {
+	p, err := FromURI("trojan://secret@[2001:db8::1]:8443")
+	if err != nil {
+		t.Fatalf("FromURI() error = %v", err)
+	}
+	if got := EndpointOf(p); got != "[2001:db8::1]:8443" {
+		t.Fatalf("EndpointOf() = %q, want [2001:db8::1]:8443", got)
+	}
+	if got := EndpointOf(&fakeProvider{}); got != "" {
+		t.Fatalf("EndpointOf(fake) = %q, want empty", got)
+	}
+}
+
+func TestFromURI_RemarksDecoded(t *testing.T) {
+	cases := []struct {
+		raw  string
+		want string
+	}{
+		{"vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?type=ws#%F0%9F%87%A9%F0%9F%87%AA%20Frankfurt%2001", "🇩🇪 Frankfurt 01"},
+		{"trojan://secret@example.com:443#Tokyo%20%E6%9D%B1%E4%BA%AC", "Tokyo 東京"},
+		{"ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ=@example.com:8388#ss%20node", "ss node"},
+		{"hy2://secret@example.com:443,20000-30000?sni=a.com#%F0%9F%9A%80%20fast", "🚀 fast"},
+		{vmessURI(t, map[string]any{"add": "example.com", "port": 443, "id": "2d67b1be-5e23-40b0-a826-4fd8dd4e650f", "ps": "🇺🇸 US-1"}), "🇺🇸 US-1"},
+		{"vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443", ""},
+	}
+	for _, c := range cases {
+		p, err := FromURI(c.raw)
+		if err != nil {
+			t.Fatalf("FromURI(%q) error = %v", c.raw, err)
+		}
+		if got := LabelOf(p); got != c.want {
+			t.Fatalf("LabelOf(%s) = %q, want %q", p.Name(), got, c.want)
+		}
+	}
+	if got := LabelOf(&fakeProvider{}); got != "" {
+		t.Fatalf("LabelOf(fake) = %q, want empty", got)
+	}
+}
@@ -14,6 +14,12 @@ type URIParser interface {
 	Parse(u *url.URL, raw string) (Provider, error)
 }
 
+// rawURIParser is implemented by parsers whose links are not always valid
+// net/url input. Registry falls back to it when url.Parse rejects the link.
+type rawURIParser interface {
+	ParseRaw(raw string) (Provider, error)
+}
+
 type Registry struct {
 	mu      sync.RWMutex
 	parsers map[string]URIParser
@@ -48,19 +54,27 @@ func (r *Registry) Register(p URIParser) error {
 func (r *Registry) Parse(raw string) (Provider, error) {
 	u, err := url.Parse(raw)
 	if err != nil {
+		if scheme, _, ok := strings.Cut(raw, "://"); ok {
+			if rp, ok := r.lookup(scheme).(rawURIParser); ok {
+				return rp.ParseRaw(raw)
+			}
+		}
 		return nil, fmt.Errorf("invalid URI: %w", err)
 	}
-	scheme := strings.ToLower(u.Scheme)
 
-	r.mu.RLock()
-	p := r.parsers[scheme]
-	r.mu.RUnlock()
+	p := r.lookup(u.Scheme)
 	if p == nil {
 		return nil, fmt.Errorf("unsupported scheme %q (supported: %s)", u.Scheme, strings.Join(r.Schemes(), ", "))
 	}
 	return p.Parse(u, raw)
 }
 
+func (r *Registry) lookup(scheme string) URIParser {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.parsers[strings.ToLower(scheme)]
+}
+
 func (r *Registry) Schemes() []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
@@ -77,6 +91,9 @@ var defaultRegistry = func() *Registry {
 	mustRegister(r, &vlessParser{})
 	mustRegister(r, &vmessParser{})
 	mustRegister(r, &shadowsocksParser{})
+	mustRegister(r, &trojanParser{})
+	mustRegister(r, &hysteria2Parser{scheme: "hysteria2"})
+	mustRegister(r, &hysteria2Parser{scheme: "hy2"})
 	return r
 }()
 
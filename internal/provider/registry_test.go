@@ -23,7 +23,7 @@ func (p *fakeParser) Parse(_ *url.URL, _ string) (Provider, error) {
 
 func TestSupportedSchemes_Default(t *testing.T) {
 	got := SupportedSchemes()
-	want := []string{"ss", "vless", "vmess"}
+	want := []string{"hy2", "hysteria2", "ss", "trojan", "vless", "vmess"}
 	if !reflect.DeepEqual(got, want) {
 		t.Fatalf("SupportedSchemes() = %v, want %v", got, want)
 	}
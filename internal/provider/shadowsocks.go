@@ -79,11 +79,18 @@ func (p *shadowsocksParser) Parse(u *url.URL, raw string) (Provider, error) {
 		SNI:        q.Get("sni"),
 		ALPN:       q.Get("alpn"),
 		Service:    q.Get("serviceName"),
+		Remark:     u.Fragment,
 	}, nil
 }
 
 func (s *Shadowsocks) Name() string { return "shadowsocks" }
 
+func (s *Shadowsocks) Label() string { return s.Remark }
+
+func (s *Shadowsocks) Endpoint() string {
+	return net.JoinHostPort(s.Address, strconv.Itoa(s.Port))
+}
+
 func (s *Shadowsocks) Outbound() (map[string]any, error) {
 	out := map[string]any{
 		"tag":      "proxy",
@@ -0,0 +1,72 @@
+package provider
+
+import "strings"
+
+// streamConfig is the transport and security part of a share link that
+// VLESS and Trojan have in common.
+type streamConfig struct {
+	Address     string
+	Network     string
+	Security    string
+	HeaderType  string
+	Host        string
+	Path        string
+	SNI         string
+	ALPN        string
+	Service     string
+	Fingerprint string
+	PublicKey   string
+	ShortID     string
+	SpiderX     string
+	PQV         string
+}
+
+// streamSettings builds the Xray streamSettings object for c.
+func streamSettings(c streamConfig) map[string]any {
+	stream := map[string]any{
+		"network":  c.Network,
+		"security": c.Security,
+	}
+	if strings.EqualFold(c.Network, "tcp") && strings.EqualFold(c.HeaderType, "http") {
+		request := map[string]any{"path": toPathList(c.Path)}
+		if hosts := toHostList(c.Host); len(hosts) > 0 {
+			request["headers"] = map[string]any{"Host": hosts}
+		}
+		stream["tcpSettings"] = map[string]any{
+			"header": map[string]any{
+				"type":    "http",
+				"request": request,
+			},
+		}
+	}
+	if strings.EqualFold(c.Network, "ws") {
+		ws := map[string]any{"path": valueOrDefault(c.Path, "/")}
+		if strings.TrimSpace(c.Host) != "" {
+			ws["headers"] = map[string]any{"Host": c.Host}
+		}
+		stream["wsSettings"] = ws
+	}
+	if strings.EqualFold(c.Network, "grpc") {
+		stream["grpcSettings"] = map[string]any{"serviceName": c.Service}
+	}
+	if strings.EqualFold(c.Security, "tls") {
+		stream["tlsSettings"] = map[string]any{
+			"serverName": firstNonEmpty(c.SNI, c.Host, c.Address),
+			"alpn":       splitCSV(c.ALPN),
+		}
+	}
+	if strings.EqualFold(c.Security, "reality") {
+		reality := map[string]any{
+			"fingerprint": valueOrDefault(c.Fingerprint, "chrome"),
+			"serverName":  firstNonEmpty(c.SNI, c.Host, c.Address),
+			"publicKey":   c.PublicKey,
+			"shortId":     c.ShortID,
+			"spiderX":     c.SpiderX,
+		}
+		if strings.TrimSpace(c.PQV) != "" {
+			reality["mldsa65Verify"] = c.PQV
+		}
+		stream["realitySettings"] = reality
+	}
+	return stream
+}
@@ -0,0 +1,47 @@
+package provider
+
+import "strings"
+
+// SubscriptionEntry is one non-empty line of a subscription feed. Exactly one
+// of Provider and Err is set.
+type SubscriptionEntry struct {
+	Line     int
+	Raw      string
+	Provider Provider
+	Err      error
+}
+
+// ParseSubscription decodes a subscription body and parses every share link
+// in it. Feeds are usually base64 of newline-separated links; plain-text
+// feeds are accepted as-is. A bad line never aborts the batch.
+func ParseSubscription(body []byte) []SubscriptionEntry {
+	text := decodeSubscriptionBody(string(body))
+
+	var out []SubscriptionEntry
+	for i, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		entry := SubscriptionEntry{Line: i + 1, Raw: line}
+		entry.Provider, entry.Err = FromURI(line)
+		out = append(out, entry)
+	}
+	return out
+}
+
+func decodeSubscriptionBody(body string) string {
+	body = strings.TrimPrefix(body, "\ufeff")
+	compact := strings.Join(strings.Fields(body), "")
+	if compact == "" || strings.Contains(compact, "://") {
+		return normalizeNewlines(body)
+	}
+	if b, err := decodeBase64Any(compact); err == nil {
+		return normalizeNewlines(string(b))
+	}
+	return normalizeNewlines(body)
+}
+
+func normalizeNewlines(v string) string {
+	return strings.ReplaceAll(v, "\r\n", "\n")
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestParseSubscription_Base64Feed(t *testing.T) {
+	links := strings.Join([]string{
+		"vless://80cbb58b-74c0-4fb5-a66e-818ffc81a3cd@example.com:443?type=ws&security=tls",
+		"",
+		"trojan://secret@example.com:443",
+		"unknown://broken",
+		"ss://YWVzLTI1Ni1nY206cGFzc3dvcmQ=@example.com:8388",
+	}, "\r\n")
+	body := base64.StdEncoding.EncodeToString([]byte(links))
+
+	entries := ParseSubscription([]byte(body))
+	if len(entries) != 4 {
+		t.Fatalf("len(entries) = %d, want 4", len(entries))
+	}
+	if entries[0].Err != nil || entries[0].Provider.Name() != "vless" {
+		t.Fatalf("entries[0] = %+v, want vless provider", entries[0])
+	}
+	if entries[1].Line != 3 || entries[1].Provider.Name() != "trojan" {
+		t.Fatalf("entries[1] = %+v, want trojan on line 3", entries[1])
+	}
+	if entries[2].Err == nil || !strings.Contains(entries[2].Err.Error(), "unsupported scheme") {
+		t.Fatalf("entries[2].Err = %v, want unsupported scheme error", entries[2].Err)
+	}
+	if entries[3].Err != nil || entries[3].Provider.Name() != "shadowsocks" {
+		t.Fatalf("entries[3] = %+v, want shadowsocks provider", entries[3])
+	}
+}
+
+func TestParseSubscription_RawURLBase64WithoutPadding(t *testing.T) {
+	links := "trojan://secret@example.com:443\nhy2://secret@example.com:443"
+	body := base64.RawURLEncoding.EncodeToString([]byte(links))
+
+	entries := ParseSubscription([]byte(body))
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if e.Err != nil {
+			t.Fatalf("line %d error = %v", e.Line, e.Err)
+		}
+	}
+}
+
+func TestParseSubscription_PlainTextFeed(t *testing.T) {
+	body := "# comment\ntrojan://secret@example.com:443\nvless://missing-port@example.com\n"
+
+	entries := ParseSubscription([]byte(body))
+	if len(entries) != 2 {
+		t.Fatalf("len(entries) = %d, want 2", len(entries))
+	}
+	if entries[0].Err != nil {
+		t.Fatalf("entries[0].Err = %v, want nil", entries[0].Err)
+	}
+	if entries[1].Err == nil {
+		t.Fatal("entries[1].Err = nil, want host/port error")
+	}
+}
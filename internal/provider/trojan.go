@@ -0,0 +1,99 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+type trojanParser struct{}
+
+func (p *trojanParser) Scheme() string { return "trojan" }
+
+func (p *trojanParser) Parse(u *url.URL, _ string) (Provider, error) {
+	if u.User == nil {
+		return nil, errors.New("trojan URI missing password")
+	}
+	password := u.User.Username()
+	if password == "" {
+		return nil, errors.New("trojan URI has empty password")
+	}
+
+	host, portStr, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		return nil, fmt.Errorf("trojan host/port parse failed: %w", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 1 || port > 65535 {
+		return nil, errors.New("invalid trojan port")
+	}
+
+	q := u.Query()
+	return &Trojan{
+		Address:     host,
+		Port:        port,
+		Password:    password,
+		Flow:        q.Get("flow"),
+		Network:     valueOrDefault(q.Get("type"), "tcp"),
+		Security:    valueOrDefault(q.Get("security"), "tls"),
+		HeaderType:  q.Get("headerType"),
+		Host:        q.Get("host"),
+		Path:        q.Get("path"),
+		SNI:         firstNonEmpty(q.Get("sni"), q.Get("peer")),
+		ALPN:        q.Get("alpn"),
+		Service:     q.Get("serviceName"),
+		Fingerprint: q.Get("fp"),
+		PublicKey:   q.Get("pbk"),
+		ShortID:     q.Get("sid"),
+		SpiderX:     q.Get("spx"),
+		PQV:         q.Get("pqv"),
+		Remark:      u.Fragment,
+	}, nil
+}
+
+func (t *Trojan) Name() string { return "trojan" }
+
+func (t *Trojan) Label() string { return t.Remark }
+
+func (t *Trojan) Endpoint() string {
+	return net.JoinHostPort(t.Address, strconv.Itoa(t.Port))
+}
+
+func (t *Trojan) Outbound() (map[string]any, error) {
+	server := map[string]any{
+		"address":  t.Address,
+		"port":     t.Port,
+		"password": t.Password,
+	}
+	if t.Flow != "" {
+		server["flow"] = t.Flow
+	}
+
+	out := map[string]any{
+		"tag":      "proxy",
+		"protocol": "trojan",
+		"settings": map[string]any{
+			"servers": []any{server},
+		},
+	}
+
+	out["streamSettings"] = streamSettings(streamConfig{
+		Address:     t.Address,
+		Network:     valueOrDefault(t.Network, "tcp"),
+		Security:    valueOrDefault(t.Security, "tls"),
+		HeaderType:  t.HeaderType,
+		Host:        t.Host,
+		Path:        t.Path,
+		SNI:         t.SNI,
+		ALPN:        t.ALPN,
+		Service:     t.Service,
+		Fingerprint: t.Fingerprint,
+		PublicKey:   t.PublicKey,
+		ShortID:     t.ShortID,
+		SpiderX:     t.SpiderX,
+		PQV:         t.PQV,
+	})
+	return out, nil
+}
@@ -8,6 +8,33 @@ type Provider interface {
 	Name() string
 }
 
+// Endpointer is implemented by providers that know their server address.
+type Endpointer interface {
+	Endpoint() string
+}
+
+// EndpointOf returns the "host:port" a provider dials, or "" if unknown.
+func EndpointOf(p Provider) string {
+	if e, ok := p.(Endpointer); ok {
+		return e.Endpoint()
+	}
+	return ""
+}
+
+// Labeler is implemented by providers that carry a display name, taken from
+// the link's #fragment or the vmess "ps" field.
+type Labeler interface {
+	Label() string
+}
+
+// LabelOf returns the provider's display name, or "" if it has none.
+func LabelOf(p Provider) string {
+	if l, ok := p.(Labeler); ok {
+		return l.Label()
+	}
+	return ""
+}
+
 type VLESS struct {
 	Address     string
 	Port        int
@@ -27,6 +54,7 @@ type VLESS struct {
 	ShortID     string
 	SpiderX     string
 	PQV         string
+	Remark      string
 }
 
 type VMess struct {
@@ -42,6 +70,7 @@ type VMess struct {
 	ALPN       string          `json:"alpn"`
 	Type       string          `json:"type"`
 	Security   string          `json:"scy"`
+	Remark     string          `json:"ps"`
 	Port       int             `json:"-"`
 	AlterID    int             `json:"-"`
 }
@@ -59,4 +88,40 @@ type Shadowsocks struct {
 	SNI        string
 	ALPN       string
 	Service    string
+	Remark     string
+}
+
+type Trojan struct {
+	Address     string
+	Port        int
+	Password    string
+	Flow        string
+	Network     string
+	Security    string
+	HeaderType  string
+	Host        string
+	Path        string
+	SNI         string
+	ALPN        string
+	Service     string
+	Fingerprint string
+	PublicKey   string
+	ShortID     string
+	SpiderX     string
+	PQV         string
+	Remark      string
+}
+
+type Hysteria2 struct {
+	Address      string
+	Port         int
+	Ports        string
+	Auth         string
+	SNI          string
+	Insecure     bool
+	Obfs         string
+	ObfsPassword string
+	PinSHA256    string
+	ALPN         string
+	Remark       string
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"net/url"
 	"strconv"
 	"strings"
 )
@@ -47,6 +48,15 @@ func decodeBase64Any(s string) ([]byte, error) {
 	return base64.RawURLEncoding.DecodeString(s)
 }
 
+// decodeFragment percent-decodes a raw #fragment, keeping it as-is when it
+// is not valid escaping.
+func decodeFragment(v string) string {
+	if d, err := url.PathUnescape(v); err == nil {
+		return d
+	}
+	return v
+}
+
 func valueOrDefault(v, d string) string {
 	if v == "" {
 		return d
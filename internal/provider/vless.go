@@ -6,7 +6,6 @@ import (
 	"net"
 	"net/url"
 	"strconv"
-	"strings"
 )
 
 type vlessParser struct{}
@@ -51,11 +50,18 @@ func (p *vlessParser) Parse(u *url.URL, _ string) (Provider, error) {
 		ShortID:     q.Get("sid"),
 		SpiderX:     q.Get("spx"),
 		PQV:         q.Get("pqv"),
+		Remark:      u.Fragment,
 	}, nil
 }
 
 func (v *VLESS) Name() string { return "vless" }
 
+func (v *VLESS) Label() string { return v.Remark }
+
+func (v *VLESS) Endpoint() string {
+	return net.JoinHostPort(v.Address, strconv.Itoa(v.Port))
+}
+
 func (v *VLESS) Outbound() (map[string]any, error) {
 	user := map[string]any{
 		"id":         v.ID,
@@ -77,52 +83,21 @@ func (v *VLESS) Outbound() (map[string]any, error) {
 		},
 	}
 
-	stream := map[string]any{
-		"network":  v.Network,
-		"security": v.Security,
-	}
-	if strings.EqualFold(v.Network, "tcp") && strings.EqualFold(v.HeaderType, "http") {
-		request := map[string]any{"path": toPathList(v.Path)}
-		if hosts := toHostList(v.Host); len(hosts) > 0 {
-			request["headers"] = map[string]any{"Host": hosts}
-		}
-		stream["tcpSettings"] = map[string]any{
-			"header": map[string]any{
-				"type":    "http",
-				"request": request,
-			},
-		}
-	}
-	if strings.EqualFold(v.Network, "ws") {
-		ws := map[string]any{"path": valueOrDefault(v.Path, "/")}
-		if strings.TrimSpace(v.Host) != "" {
-			ws["headers"] = map[string]any{"Host": v.Host}
-		}
-		stream["wsSettings"] = ws
-	}
-	if strings.EqualFold(v.Network, "grpc") {
-		stream["grpcSettings"] = map[string]any{"serviceName": v.Service}
-	}
-	if strings.EqualFold(v.Security, "tls") {
-		stream["tlsSettings"] = map[string]any{
-			"serverName": firstNonEmpty(v.SNI, v.Host, v.Address),
-			"alpn":       splitCSV(v.ALPN),
-		}
-	}
-	if strings.EqualFold(v.Security, "reality") {
-		reality := map[string]any{
-			"fingerprint": valueOrDefault(v.Fingerprint, "chrome"),
-			"serverName":  firstNonEmpty(v.SNI, v.Host, v.Address),
-			"publicKey":   v.PublicKey,
-			"shortId":     v.ShortID,
-			"spiderX":     v.SpiderX,
-		}
-		if strings.TrimSpace(v.PQV) != "" {
-			reality["mldsa65Verify"] = v.PQV
-		}
-		stream["realitySettings"] = reality
-	}
-
-	out["streamSettings"] = stream
+	out["streamSettings"] = streamSettings(streamConfig{
+		Address:     v.Address,
+		Network:     v.Network,
+		Security:    v.Security,
+		HeaderType:  v.HeaderType,
+		Host:        v.Host,
+		Path:        v.Path,
+		SNI:         v.SNI,
+		ALPN:        v.ALPN,
+		Service:     v.Service,
+		Fingerprint: v.Fingerprint,
+		PublicKey:   v.PublicKey,
+		ShortID:     v.ShortID,
+		SpiderX:     v.SpiderX,
+		PQV:         v.PQV,
+	})
 	return out, nil
 }
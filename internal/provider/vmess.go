@@ -4,7 +4,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/url"
+	"strconv"
 	"strings"
 )
 
@@ -56,6 +58,12 @@ func (p *vmessParser) Parse(_ *url.URL, raw string) (Provider, error) {
 
 func (v *VMess) Name() string { return "vmess" }
 
+func (v *VMess) Label() string { return v.Remark }
+
+func (v *VMess) Endpoint() string {
+	return net.JoinHostPort(v.Address, strconv.Itoa(v.Port))
+}
+
 func (v *VMess) Outbound() (map[string]any, error) {
 	out := map[string]any{
 		"tag":      "proxy",